@@ -9,7 +9,10 @@ require (
 	github.com/djherbis/buffer v1.2.0
 	github.com/go-co-op/gocron/v2 v2.16.6
 	github.com/jackc/pgx/v5 v5.7.6
+	github.com/jedisct1/go-minisign v0.0.0-20230811132847-661be99b8267
 	github.com/minio/minio-go/v7 v7.0.95
+	github.com/pkg/sftp v1.13.9
+	golang.org/x/crypto v0.42.0
 	golang.org/x/term v0.35.0
 )
 
@@ -23,13 +26,13 @@ require (
 	github.com/jonboulle/clockwork v0.5.0 // indirect
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/klauspost/cpuid/v2 v2.3.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/minio/crc64nvme v1.1.1 // indirect
 	github.com/minio/md5-simd v1.1.2 // indirect
 	github.com/philhofer/fwd v1.2.0 // indirect
 	github.com/robfig/cron/v3 v3.0.1 // indirect
 	github.com/rs/xid v1.6.0 // indirect
 	github.com/tinylib/msgp v1.4.0 // indirect
-	golang.org/x/crypto v0.42.0 // indirect
 	golang.org/x/net v0.44.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
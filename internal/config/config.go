@@ -1,56 +1,257 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path"
+	"slices"
 	"strings"
 
 	"github.com/BurntSushi/toml"
 )
 
+// pg_dump output formats
+const (
+	FormatCustom    = "custom"
+	FormatDirectory = "directory"
+)
+
+// What to do when uploading to some (but not all) destinations fails
+const (
+	PartialFailureFail = "fail" // report the backup as failed (successful uploads are kept)
+	PartialFailureWarn = "warn" // print a warning and report the backup as successful
+)
+
+// What to do when a hook fails
+const (
+	HookFailureAbort = "abort" // stop the operation (or report an error if it already happened)
+	HookFailureWarn  = "warn"  // print a warning and carry on
+)
+
+// SQL executed against the database and shell commands run before and after
+// an operation. Each SQL file is executed as a whole and may contain multiple
+// statements. A failing pre command always prevents the operation from running.
+type Hooks struct {
+	PreSQL       []string `toml:"pre_sql"`
+	PreSQLFiles  []string `toml:"pre_sql_files"`
+	PostSQL      []string `toml:"post_sql"`
+	PostSQLFiles []string `toml:"post_sql_files"`
+	PreCommand   string   `toml:"pre_command"`
+	PostCommand  string   `toml:"post_command"`
+	OnFailure    string   `toml:"on_failure"`
+}
+
+func (h Hooks) clone() Hooks {
+	h.PreSQL = slices.Clone(h.PreSQL)
+	h.PreSQLFiles = slices.Clone(h.PreSQLFiles)
+	h.PostSQL = slices.Clone(h.PostSQL)
+	h.PostSQLFiles = slices.Clone(h.PostSQLFiles)
+	return h
+}
+
 type Backup struct {
 	Prefix    string `toml:"prefix"`
 	Retention int    `toml:"retention"`
 	Schedule  string `toml:"schedule"`
+	Format    string `toml:"format"`
+	Jobs      int    `toml:"jobs"`
+
+	Schemas          []string `toml:"schemas"`
+	ExcludeSchemas   []string `toml:"exclude_schemas"`
+	Tables           []string `toml:"tables"`
+	ExcludeTables    []string `toml:"exclude_tables"`
+	ExcludeTableData []string `toml:"exclude_table_data"`
+
+	Globals         bool `toml:"globals"`
+	NoRolePasswords bool `toml:"no_role_passwords"`
+
+	PartialFailure string `toml:"partial_failure"`
+
+	AllDatabases     bool     `toml:"all_databases"`
+	Databases        []string `toml:"databases"`
+	ExcludeDatabases []string `toml:"exclude_databases"`
+
+	Hooks Hooks `toml:"hooks"`
 }
 
+func (b Backup) clone() Backup {
+	b.Schemas = slices.Clone(b.Schemas)
+	b.ExcludeSchemas = slices.Clone(b.ExcludeSchemas)
+	b.Tables = slices.Clone(b.Tables)
+	b.ExcludeTables = slices.Clone(b.ExcludeTables)
+	b.ExcludeTableData = slices.Clone(b.ExcludeTableData)
+	b.Databases = slices.Clone(b.Databases)
+	b.ExcludeDatabases = slices.Clone(b.ExcludeDatabases)
+	b.Hooks = b.Hooks.clone()
+	return b
+}
+
+// How to handle other connections to the target database during a restore
+const (
+	ConnectionsIgnore    = ""          // leave other connections alone
+	ConnectionsShow      = "show"      // list other connections before confirming
+	ConnectionsTerminate = "terminate" // terminate other connections before restoring
+	ConnectionsBlock     = "block"     // also block new connections while restoring
+)
+
 type Restore struct {
-	Schemas []string `toml:"schemas"`
+	PGURL          string   `toml:"pg_url"`
+	Schemas        []string `toml:"schemas"`
+	CreateDatabase bool     `toml:"create_database"`
+	Owner          string   `toml:"owner"`
+	Encoding       string   `toml:"encoding"`
+	Template       string   `toml:"template"`
+	Swap           bool     `toml:"swap"`
+	Connections    string   `toml:"connections"`
+	Jobs           int      `toml:"jobs"`
+
+	ExcludeSchemas    []string `toml:"exclude_schemas"`
+	Tables            []string `toml:"tables"`
+	ExcludeTables     []string `toml:"exclude_tables"`
+	DataOnly          bool     `toml:"data_only"`
+	SchemaOnly        bool     `toml:"schema_only"`
+	SingleTransaction bool     `toml:"single_transaction"`
+	Globals           bool     `toml:"globals"`
+
+	Hooks Hooks `toml:"hooks"`
+}
+
+func (r Restore) clone() Restore {
+	r.Schemas = slices.Clone(r.Schemas)
+	r.ExcludeSchemas = slices.Clone(r.ExcludeSchemas)
+	r.Tables = slices.Clone(r.Tables)
+	r.ExcludeTables = slices.Clone(r.ExcludeTables)
+	r.Hooks = r.Hooks.clone()
+	return r
+}
+
+type Prune struct {
+	Hooks Hooks `toml:"hooks"`
 }
 
 type Encryption struct {
 	PublicKeys []string `toml:"public_keys"`
 }
 
+type Signing struct {
+	PrivateKey string `toml:"private_key"`
+	PublicKey  string `toml:"public_key"`
+}
+
+// Credentials used by sftp:// destinations
+type SFTP struct {
+	PrivateKeyFile string `toml:"private_key_file"`
+	// defaults to ~/.ssh/known_hosts
+	KnownHostsFile string `toml:"known_hosts_file"`
+}
+
 type Config struct {
 	S3 S3 `toml:"-"`
+	// Additional destinations that every backup is also uploaded to
+	Mirrors []S3 `toml:"-"`
+
+	// Name of the job (only set for configs defined within [[job]] tables)
+	Name string `toml:"-"`
+	// Jobs defined within [[job]] tables (each inherits the top-level settings)
+	Jobs []Config `toml:"-"`
+
+	PGURL        string     `toml:"pg_url"`
+	S3URL        string     `toml:"s3_url"`
+	Destinations []string   `toml:"destinations"`
+	Backup       Backup     `toml:"backup"`
+	Restore      Restore    `toml:"restore"`
+	Prune        Prune      `toml:"prune"`
+	Encryption   Encryption `toml:"encryption"`
+	Signing      Signing    `toml:"signing"`
+	SFTP         SFTP       `toml:"sftp"`
+}
+
+// Deep copy a config (none of its slices are shared with the original)
+func (cfg Config) clone() Config {
+	cfg.Mirrors = slices.Clone(cfg.Mirrors)
+	cfg.Destinations = slices.Clone(cfg.Destinations)
+	cfg.Backup = cfg.Backup.clone()
+	cfg.Restore = cfg.Restore.clone()
+	cfg.Prune.Hooks = cfg.Prune.Hooks.clone()
+	cfg.Encryption.PublicKeys = slices.Clone(cfg.Encryption.PublicKeys)
+
+	if cfg.Jobs != nil {
+		jobs := make([]Config, len(cfg.Jobs))
+		for i, job := range cfg.Jobs {
+			jobs[i] = job.clone()
+		}
+		cfg.Jobs = jobs
+	}
 
-	PGURL      string     `toml:"pg_url"`
-	S3URL      string     `toml:"s3_url"`
-	Backup     Backup     `toml:"backup"`
-	Restore    Restore    `toml:"restore"`
-	Encryption Encryption `toml:"encryption"`
+	return cfg
 }
 
 func Read(data string) (Config, error) {
 	// init Config struct with default values
 	cfg := Config{
 		Backup: Backup{
-			Prefix: "pg2s3",
+			Prefix:         "pg2s3",
+			Format:         FormatCustom,
+			PartialFailure: PartialFailureFail,
+			Hooks: Hooks{
+				OnFailure: HookFailureAbort,
+			},
 		},
 		Restore: Restore{
 			Schemas: []string{},
+			Hooks: Hooks{
+				OnFailure: HookFailureAbort,
+			},
+		},
+		Prune: Prune{
+			Hooks: Hooks{
+				OnFailure: HookFailureAbort,
+			},
 		},
 	}
-	meta, err := toml.Decode(data, &cfg)
+
+	// jobs are decoded separately since they inherit the top-level settings
+	file := struct {
+		Config
+		Job []toml.Primitive `toml:"job"`
+	}{Config: cfg}
+
+	meta, err := toml.Decode(data, &file)
 	if err != nil {
 		return Config{}, err
 	}
+	cfg = file.Config
+
+	var jobs []Config
+	for _, primitive := range file.Job {
+		// copy the config so that decoding into the job can't modify the original's slices
+		job := cfg.clone()
+
+		var name struct {
+			Name string `toml:"name"`
+		}
+		err := meta.PrimitiveDecode(primitive, &name)
+		if err != nil {
+			return Config{}, err
+		}
+
+		err = meta.PrimitiveDecode(primitive, &job)
+		if err != nil {
+			return Config{}, err
+		}
+
+		job.Name = name.Name
+		jobs = append(jobs, job)
+	}
 
 	// gather extra values
 	var extra []string
 	for _, keys := range meta.Undecoded() {
 		key := keys[0]
+		if key == "job" && len(keys) > 1 {
+			key = strings.Join(keys[:2], ".")
+		}
 		extra = append(extra, key)
 	}
 
@@ -67,22 +268,74 @@ func Read(data string) (Config, error) {
 		present[key] = true
 	}
 
-	required := []string{
-		"pg_url",
-		"s3_url",
+	// without jobs, the top-level config must be complete
+	if len(jobs) == 0 {
+		required := []string{
+			"pg_url",
+			"s3_url",
+		}
+
+		// ensure required keys are present
+		missing := []string{}
+		for _, key := range required {
+			if _, ok := present[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+
+		if len(missing) > 0 {
+			msg := strings.Join(missing, ", ")
+			return Config{}, fmt.Errorf("missing config values: %s", msg)
+		}
+
+		return finishConfig(cfg)
 	}
 
-	// ensure required keys are present
-	missing := []string{}
-	for _, key := range required {
-		if _, ok := present[key]; !ok {
-			missing = append(missing, key)
+	// otherwise, each job must be complete (either directly or via inheritance)
+	names := make(map[string]bool)
+	for i, job := range jobs {
+		if job.Name == "" {
+			return Config{}, fmt.Errorf("missing config values: job[%d].name", i)
+		}
+		if names[job.Name] {
+			return Config{}, fmt.Errorf("duplicate job name: %q", job.Name)
+		}
+		names[job.Name] = true
+
+		missing := []string{}
+		if job.PGURL == "" {
+			missing = append(missing, "pg_url")
+		}
+		if job.S3URL == "" {
+			missing = append(missing, "s3_url")
+		}
+
+		if len(missing) > 0 {
+			msg := strings.Join(missing, ", ")
+			return Config{}, fmt.Errorf("missing config values for job %q: %s", job.Name, msg)
+		}
+
+		jobs[i], err = finishConfig(job)
+		if err != nil {
+			return Config{}, fmt.Errorf("job %q: %w", job.Name, err)
 		}
 	}
 
-	if len(missing) > 0 {
-		msg := strings.Join(missing, ", ")
-		return Config{}, fmt.Errorf("missing config values: %s", msg)
+	// the top-level settings themselves only serve as defaults
+	err = Validate(cfg)
+	if err != nil {
+		return Config{}, err
+	}
+
+	cfg.Jobs = jobs
+	return cfg, nil
+}
+
+// validate a complete config and parse its S3 URL
+func finishConfig(cfg Config) (Config, error) {
+	err := Validate(cfg)
+	if err != nil {
+		return Config{}, err
 	}
 
 	// parse S3 URL into S3 struct
@@ -92,9 +345,268 @@ func Read(data string) (Config, error) {
 	}
 
 	cfg.S3 = s3
+
+	// parse any additional destinations
+	cfg.Mirrors = nil
+	for _, destination := range cfg.Destinations {
+		if destination == cfg.S3URL {
+			return Config{}, fmt.Errorf("destination %q duplicates s3_url", destination)
+		}
+
+		mirror, err := ParseS3URL(destination)
+		if err != nil {
+			return Config{}, err
+		}
+
+		cfg.Mirrors = append(cfg.Mirrors, mirror)
+	}
+
+	// SFTP only supports key-based authentication
+	for _, location := range append([]S3{cfg.S3}, cfg.Mirrors...) {
+		if location.Scheme == SchemeSFTP && cfg.SFTP.PrivateKeyFile == "" {
+			return Config{}, errors.New("sftp destinations require sftp.private_key_file")
+		}
+	}
+
 	return cfg, nil
 }
 
+// Select a job by name. Configs without jobs are returned as-is when no name is given.
+func (cfg Config) Job(name string) (Config, error) {
+	if len(cfg.Jobs) == 0 {
+		if name != "" {
+			return Config{}, fmt.Errorf("job %q not found (no jobs are configured)", name)
+		}
+		return cfg, nil
+	}
+
+	if name == "" {
+		if len(cfg.Jobs) == 1 {
+			return cfg.Jobs[0], nil
+		}
+		return Config{}, errors.New("multiple jobs are configured, select one with -job")
+	}
+
+	for _, job := range cfg.Jobs {
+		if job.Name == name {
+			return job, nil
+		}
+	}
+
+	return Config{}, fmt.Errorf("job %q not found", name)
+}
+
+// Check for invalid or conflicting values
+func Validate(cfg Config) error {
+	switch cfg.Backup.Format {
+	case FormatCustom, FormatDirectory:
+	default:
+		return fmt.Errorf("invalid backup.format: %q", cfg.Backup.Format)
+	}
+
+	if cfg.Backup.Jobs < 0 {
+		return fmt.Errorf("invalid backup.jobs: %d", cfg.Backup.Jobs)
+	}
+
+	// only directory format dumps can be parallelized
+	if cfg.Backup.Jobs > 1 && cfg.Backup.Format != FormatDirectory {
+		return fmt.Errorf("backup.jobs requires backup.format = %q", FormatDirectory)
+	}
+
+	switch cfg.Backup.PartialFailure {
+	case PartialFailureFail, PartialFailureWarn:
+	default:
+		return fmt.Errorf("invalid backup.partial_failure: %q", cfg.Backup.PartialFailure)
+	}
+
+	if cfg.Backup.NoRolePasswords && !cfg.Backup.Globals {
+		return errors.New("backup.no_role_passwords requires backup.globals")
+	}
+
+	if !cfg.Backup.AllDatabases && (len(cfg.Backup.Databases) > 0 || len(cfg.Backup.ExcludeDatabases) > 0) {
+		return errors.New("backup.databases and backup.exclude_databases require backup.all_databases")
+	}
+
+	for _, list := range [][]string{cfg.Backup.Databases, cfg.Backup.ExcludeDatabases} {
+		for _, pattern := range list {
+			// the only possible error is ErrBadPattern
+			_, err := path.Match(pattern, "")
+			if err != nil || pattern == "" {
+				return fmt.Errorf("invalid database pattern: %q", pattern)
+			}
+		}
+	}
+
+	patterns := []struct {
+		key    string
+		values []string
+	}{
+		{"backup.schemas", cfg.Backup.Schemas},
+		{"backup.exclude_schemas", cfg.Backup.ExcludeSchemas},
+		{"backup.tables", cfg.Backup.Tables},
+		{"backup.exclude_tables", cfg.Backup.ExcludeTables},
+		{"backup.exclude_table_data", cfg.Backup.ExcludeTableData},
+		{"restore.schemas", cfg.Restore.Schemas},
+		{"restore.exclude_schemas", cfg.Restore.ExcludeSchemas},
+		{"restore.tables", cfg.Restore.Tables},
+		{"restore.exclude_tables", cfg.Restore.ExcludeTables},
+	}
+	for _, p := range patterns {
+		err := validatePatterns(p.key, p.values)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := validateConflicts("backup.databases", cfg.Backup.Databases, "backup.exclude_databases", cfg.Backup.ExcludeDatabases)
+	if err != nil {
+		return err
+	}
+
+	err = validateConflicts("backup.schemas", cfg.Backup.Schemas, "backup.exclude_schemas", cfg.Backup.ExcludeSchemas)
+	if err != nil {
+		return err
+	}
+
+	err = validateConflicts("backup.tables", cfg.Backup.Tables, "backup.exclude_tables", cfg.Backup.ExcludeTables)
+	if err != nil {
+		return err
+	}
+
+	err = validateConflicts("restore.schemas", cfg.Restore.Schemas, "restore.exclude_schemas", cfg.Restore.ExcludeSchemas)
+	if err != nil {
+		return err
+	}
+
+	err = validateConflicts("restore.tables", cfg.Restore.Tables, "restore.exclude_tables", cfg.Restore.ExcludeTables)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Restore.DataOnly && cfg.Restore.SchemaOnly {
+		return errors.New("restore.data_only and restore.schema_only can't be used together")
+	}
+
+	// swapping in a partial restore would replace the live database with a subset of it
+	if cfg.Restore.Swap {
+		partial := []struct {
+			key string
+			set bool
+		}{
+			{"restore.schemas", len(cfg.Restore.Schemas) > 0},
+			{"restore.exclude_schemas", len(cfg.Restore.ExcludeSchemas) > 0},
+			{"restore.tables", len(cfg.Restore.Tables) > 0},
+			{"restore.exclude_tables", len(cfg.Restore.ExcludeTables) > 0},
+			{"restore.data_only", cfg.Restore.DataOnly},
+			{"restore.schema_only", cfg.Restore.SchemaOnly},
+		}
+		for _, p := range partial {
+			if p.set {
+				return fmt.Errorf("restore.swap can't be used with %s", p.key)
+			}
+		}
+	}
+
+	if cfg.Restore.SingleTransaction && cfg.Restore.Jobs > 1 {
+		return errors.New("restore.single_transaction can't be used with restore.jobs")
+	}
+
+	switch cfg.Restore.Connections {
+	case ConnectionsIgnore, ConnectionsShow, ConnectionsTerminate, ConnectionsBlock:
+	default:
+		return fmt.Errorf("invalid restore.connections: %q", cfg.Restore.Connections)
+	}
+
+	if cfg.Restore.Jobs < 0 {
+		return fmt.Errorf("invalid restore.jobs: %d", cfg.Restore.Jobs)
+	}
+
+	// parallel restore workers connect after connections have been blocked
+	if cfg.Restore.Jobs > 1 && cfg.Restore.Connections == ConnectionsBlock {
+		return fmt.Errorf("restore.jobs can't be used with restore.connections = %q", ConnectionsBlock)
+	}
+
+	err = validateHooks("backup.hooks", cfg.Backup.Hooks)
+	if err != nil {
+		return err
+	}
+
+	err = validateHooks("restore.hooks", cfg.Restore.Hooks)
+	if err != nil {
+		return err
+	}
+
+	err = validateHooks("prune.hooks", cfg.Prune.Hooks)
+	if err != nil {
+		return err
+	}
+
+	// prune doesn't connect to the database
+	if len(cfg.Prune.Hooks.PreSQL) > 0 || len(cfg.Prune.Hooks.PreSQLFiles) > 0 ||
+		len(cfg.Prune.Hooks.PostSQL) > 0 || len(cfg.Prune.Hooks.PostSQLFiles) > 0 {
+		return errors.New("prune.hooks only supports pre_command and post_command")
+	}
+
+	return nil
+}
+
+func validateHooks(key string, hooks Hooks) error {
+	switch hooks.OnFailure {
+	case HookFailureAbort, HookFailureWarn:
+	default:
+		return fmt.Errorf("invalid %s.on_failure: %q", key, hooks.OnFailure)
+	}
+
+	statements := append(append([]string{}, hooks.PreSQL...), hooks.PostSQL...)
+	for _, stmt := range statements {
+		if strings.TrimSpace(stmt) == "" {
+			return fmt.Errorf("invalid %s: statements must not be empty", key)
+		}
+	}
+
+	files := append(append([]string{}, hooks.PreSQLFiles...), hooks.PostSQLFiles...)
+	for _, file := range files {
+		if strings.TrimSpace(file) == "" {
+			return fmt.Errorf("invalid %s: file paths must not be empty", key)
+		}
+	}
+
+	return nil
+}
+
+// ensure each pattern is non-empty and well-formed (balanced double quotes, single line)
+func validatePatterns(key string, patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("invalid %s: patterns must not be empty", key)
+		}
+		if strings.ContainsAny(pattern, "\x00\r\n") {
+			return fmt.Errorf("invalid %s: %q", key, pattern)
+		}
+		if strings.Count(pattern, `"`)%2 != 0 {
+			return fmt.Errorf("invalid %s: unbalanced quotes in %q", key, pattern)
+		}
+	}
+
+	return nil
+}
+
+// ensure no pattern is both included and excluded
+func validateConflicts(includeKey string, include []string, excludeKey string, exclude []string) error {
+	excluded := make(map[string]bool)
+	for _, pattern := range exclude {
+		excluded[pattern] = true
+	}
+
+	for _, pattern := range include {
+		if excluded[pattern] {
+			return fmt.Errorf("%q is present in both %s and %s", pattern, includeKey, excludeKey)
+		}
+	}
+
+	return nil
+}
+
 func ReadFile(path string) (Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -1,15 +1,34 @@
 package config
 
 import (
+	"errors"
+	"net"
 	"net/url"
 	"strings"
 )
 
+// Supported storage backends
+const (
+	SchemeS3   = "s3"
+	SchemeFile = "file"
+	SchemeSFTP = "sftp"
+)
+
+// Location that backups are stored in (despite the name, this isn't necessarily S3)
 type S3 struct {
+	Scheme string
+
+	// directory holding backups (only used by the "file" and "sftp" schemes)
+	Path string
+	// SSH user (only used by the "sftp" scheme)
+	User string
+
 	Endpoint        string
 	AccessKeyID     string
 	SecretAccessKey string
 	BucketName      string
+	// Prefix prepended to object keys (empty or ending in "/")
+	Prefix string
 }
 
 func ParseS3URL(s3URL string) (S3, error) {
@@ -18,16 +37,64 @@ func ParseS3URL(s3URL string) (S3, error) {
 		return S3{}, err
 	}
 
+	// backups stored in a local directory (or NFS mount)
+	if u.Scheme == SchemeFile {
+		if u.Host != "" && u.Host != "localhost" {
+			return S3{}, errors.New("file URLs must not specify a remote host")
+		}
+		if u.Path == "" || u.Path == "/" {
+			return S3{}, errors.New("file URLs must specify a directory")
+		}
+
+		s3 := S3{
+			Scheme: SchemeFile,
+			Path:   u.Path,
+		}
+		return s3, nil
+	}
+
+	// backups stored on a remote host via SFTP
+	if u.Scheme == SchemeSFTP {
+		if u.User.Username() == "" {
+			return S3{}, errors.New("sftp URLs must specify a user")
+		}
+		if _, ok := u.User.Password(); ok {
+			return S3{}, errors.New("sftp URLs must not specify a password (only key-based authentication is supported)")
+		}
+		if u.Path == "" || u.Path == "/" {
+			return S3{}, errors.New("sftp URLs must specify a directory")
+		}
+
+		endpoint := u.Host
+		if u.Port() == "" {
+			endpoint = net.JoinHostPort(u.Hostname(), "22")
+		}
+
+		s3 := S3{
+			Scheme:   SchemeSFTP,
+			Endpoint: endpoint,
+			User:     u.User.Username(),
+			Path:     u.Path,
+		}
+		return s3, nil
+	}
+
 	endpoint := u.Host
 	accessKeyID := u.User.Username()
 	secretAccessKey, _ := u.User.Password()
-	bucketName := strings.TrimLeft(u.Path, "/")
+	// anything after the bucket name is used as a key prefix
+	bucketName, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if prefix != "" {
+		prefix += "/"
+	}
 
 	s3 := S3{
+		Scheme:          SchemeS3,
 		Endpoint:        endpoint,
 		AccessKeyID:     accessKeyID,
 		SecretAccessKey: secretAccessKey,
 		BucketName:      bucketName,
+		Prefix:          prefix,
 	}
 	return s3, nil
 }
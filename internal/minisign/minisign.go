@@ -0,0 +1,232 @@
+package minisign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Minimal implementation of the minisign key and signature formats:
+// https://jedisct1.github.io/minisign/
+//
+// Only prehashed (BLAKE2b-512) signatures and unencrypted secret keys
+// are supported. Signatures produced here can be verified by minisign.
+
+var (
+	algEd25519 = []byte("Ed")
+	algHashed  = []byte("ED")
+	algKDFNone = []byte{0, 0}
+	algCksum   = []byte("B2")
+)
+
+const (
+	keyIDSize      = 8
+	publicKeySize  = 2 + keyIDSize + ed25519.PublicKeySize
+	privateKeySize = 2 + 2 + 2 + 32 + 8 + 8 + keyIDSize + ed25519.PrivateKeySize + 32
+	signatureSize  = 2 + keyIDSize + ed25519.SignatureSize
+)
+
+type PublicKey struct {
+	ID  uint64
+	Key ed25519.PublicKey
+}
+
+type PrivateKey struct {
+	ID  uint64
+	Key ed25519.PrivateKey
+}
+
+func (k PublicKey) String() string {
+	var buf bytes.Buffer
+	buf.Write(algEd25519)
+	binary.Write(&buf, binary.LittleEndian, k.ID)
+	buf.Write(k.Key)
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func (k PrivateKey) String() string {
+	var keynum bytes.Buffer
+	binary.Write(&keynum, binary.LittleEndian, k.ID)
+	keynum.Write(k.Key)
+
+	var buf bytes.Buffer
+	buf.Write(algEd25519)
+	buf.Write(algKDFNone)
+	buf.Write(algCksum)
+	buf.Write(make([]byte, 32+8+8)) // unused KDF salt, opslimit, and memlimit
+	buf.Write(keynum.Bytes())
+	buf.Write(checksum(keynum.Bytes()))
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func (k PrivateKey) Public() PublicKey {
+	key := PublicKey{
+		ID:  k.ID,
+		Key: k.Key.Public().(ed25519.PublicKey),
+	}
+	return key
+}
+
+func GenerateKey() (PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+
+	var id [keyIDSize]byte
+	_, err = rand.Read(id[:])
+	if err != nil {
+		return PrivateKey{}, err
+	}
+
+	key := PrivateKey{
+		ID:  binary.LittleEndian.Uint64(id[:]),
+		Key: priv,
+	}
+	return key, nil
+}
+
+// Parse a public key (either bare or with its untrusted comment line)
+func ParsePublicKey(s string) (PublicKey, error) {
+	data, err := decodeKey(s)
+	if err != nil {
+		return PublicKey{}, errors.New("invalid minisign public key")
+	}
+
+	if len(data) != publicKeySize || !bytes.Equal(data[:2], algEd25519) {
+		return PublicKey{}, errors.New("invalid minisign public key")
+	}
+
+	key := PublicKey{
+		ID:  binary.LittleEndian.Uint64(data[2 : 2+keyIDSize]),
+		Key: ed25519.PublicKey(data[2+keyIDSize:]),
+	}
+	return key, nil
+}
+
+// Parse an unencrypted private key (either bare or with its untrusted comment line)
+func ParsePrivateKey(s string) (PrivateKey, error) {
+	data, err := decodeKey(s)
+	if err != nil {
+		return PrivateKey{}, errors.New("invalid minisign private key")
+	}
+
+	if len(data) != privateKeySize || !bytes.Equal(data[:2], algEd25519) {
+		return PrivateKey{}, errors.New("invalid minisign private key")
+	}
+	if !bytes.Equal(data[2:4], algKDFNone) {
+		return PrivateKey{}, errors.New("encrypted minisign private keys are not supported")
+	}
+
+	// the minisign tool leaves the checksum of unencrypted keys empty
+	keynum := data[2+2+2+32+8+8 : privateKeySize-32]
+	chk := data[privateKeySize-32:]
+	if !bytes.Equal(chk, make([]byte, 32)) && !bytes.Equal(checksum(keynum), chk) {
+		return PrivateKey{}, errors.New("invalid minisign private key checksum")
+	}
+
+	key := PrivateKey{
+		ID:  binary.LittleEndian.Uint64(keynum[:keyIDSize]),
+		Key: ed25519.PrivateKey(keynum[keyIDSize:]),
+	}
+	return key, nil
+}
+
+// NewHash returns the hash used to prehash signed data
+func NewHash() hash.Hash {
+	h, _ := blake2b.New512(nil)
+	return h
+}
+
+// Sign a prehashed digest, returning the contents of a minisign signature file
+func Sign(key PrivateKey, digest []byte, trustedComment string) []byte {
+	if strings.ContainsAny(trustedComment, "\r\n") {
+		trustedComment = strings.NewReplacer("\r", " ", "\n", " ").Replace(trustedComment)
+	}
+
+	var sig bytes.Buffer
+	sig.Write(algHashed)
+	binary.Write(&sig, binary.LittleEndian, key.ID)
+	signature := ed25519.Sign(key.Key, digest)
+	sig.Write(signature)
+
+	// the global signature covers the signature and the trusted comment
+	global := ed25519.Sign(key.Key, globalMessage(signature, trustedComment))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "untrusted comment: signature from pg2s3 secret key\n")
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(sig.Bytes()))
+	fmt.Fprintf(&out, "trusted comment: %s\n", trustedComment)
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(global))
+	return out.Bytes()
+}
+
+// Verify a minisign signature file against a prehashed digest, returning its trusted comment
+func Verify(key PublicKey, digest []byte, signatureFile []byte) (string, error) {
+	lines := strings.Split(strings.TrimSpace(string(signatureFile)), "\n")
+	if len(lines) != 4 {
+		return "", errors.New("invalid minisign signature")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != signatureSize {
+		return "", errors.New("invalid minisign signature")
+	}
+	if !bytes.Equal(sig[:2], algHashed) {
+		return "", errors.New("unsupported minisign signature algorithm")
+	}
+
+	id := binary.LittleEndian.Uint64(sig[2 : 2+keyIDSize])
+	if id != key.ID {
+		return "", fmt.Errorf("signature key ID %016X does not match public key ID %016X", id, key.ID)
+	}
+
+	signature := sig[2+keyIDSize:]
+	if !ed25519.Verify(key.Key, digest, signature) {
+		return "", errors.New("signature verification failed")
+	}
+
+	trustedComment, ok := strings.CutPrefix(strings.TrimRight(lines[2], "\r"), "trusted comment: ")
+	if !ok {
+		return "", errors.New("invalid minisign trusted comment")
+	}
+
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return "", errors.New("invalid minisign global signature")
+	}
+	if !ed25519.Verify(key.Key, globalMessage(signature, trustedComment), global) {
+		return "", errors.New("trusted comment verification failed")
+	}
+
+	return trustedComment, nil
+}
+
+func globalMessage(signature []byte, trustedComment string) []byte {
+	var msg []byte
+	msg = append(msg, signature...)
+	msg = append(msg, trustedComment...)
+	return msg
+}
+
+func checksum(keynum []byte) []byte {
+	h, _ := blake2b.New256(nil)
+	h.Write(algEd25519)
+	h.Write(keynum)
+	return h.Sum(nil)
+}
+
+// decode the base64 key data, skipping an optional comment line
+func decodeKey(s string) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+	return base64.StdEncoding.DecodeString(line)
+}
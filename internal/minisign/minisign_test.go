@@ -0,0 +1,186 @@
+package minisign_test
+
+import (
+	"strings"
+	"testing"
+
+	reference "github.com/jedisct1/go-minisign"
+
+	"github.com/theandrew168/pg2s3/internal/minisign"
+)
+
+// Unencrypted key pair generated by the minisign tool (minisign -G -W)
+const (
+	cliPrivateKey = `untrusted comment: minisign encrypted secret key
+RWQAAEIyAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAOItWpGuGQbG4C9WXaxEYLgZ2xxuqfbuZmDgAhQ8Unot8t7SyxZ0nVh0gESesJ6Ay57fGFJ9T1ajVmanT7MFMCCDbPZ8uqDcSAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+`
+	cliPublicKey = `untrusted comment: minisign public key B141866BA4568B38
+RWQ4i1aka4ZBsR0gESesJ6Ay57fGFJ9T1ajVmanT7MFMCCDbPZ8uqDcS
+`
+)
+
+// Prehashed signature of "test" made by the minisign tool (minisign -S -H)
+const (
+	cliSignaturePublicKey = "RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3"
+	cliSignature          = `untrusted comment: signature from minisign secret key
+RUQf6LRCGA9i559r3g7V1qNyJDApGip8MfqcadIgT9CuhV3EMhHoN1mGTkUidF/z7SrlQgXdy8ofjb7bNJJylDOocrCo8KLzZwo=
+trusted comment: timestamp:1635443258	file:test	hashed
+/cj37GK60vryibFn+ftOgbCvW9NKhKYgjVpFFQUcWPAnjO23wrvVDTt7cloNC06maoBli9q6qwZDXXoaxweICQ==
+`
+)
+
+func digest(data string) []byte {
+	h := minisign.NewHash()
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func TestKeys(t *testing.T) {
+	key, err := minisign.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// round trip both keys through their string representations
+	priv, err := minisign.ParsePrivateKey(key.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if priv.ID != key.ID || !priv.Key.Equal(key.Key) {
+		t.Errorf("private key did not round trip")
+	}
+
+	pub, err := minisign.ParsePublicKey(key.Public().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.ID != key.ID || !pub.Key.Equal(key.Public().Key) {
+		t.Errorf("public key did not round trip")
+	}
+
+	// keys with comment lines should also be accepted
+	withComment := "untrusted comment: minisign public key\n" + key.Public().String() + "\n"
+	_, err = minisign.ParsePublicKey(withComment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// public and private keys should not be interchangeable
+	_, err = minisign.ParsePublicKey(key.String())
+	if err == nil {
+		t.Errorf("private key should not parse as a public key")
+	}
+	_, err = minisign.ParsePrivateKey(key.Public().String())
+	if err == nil {
+		t.Errorf("public key should not parse as a private key")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key, err := minisign.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := minisign.Sign(key, digest("hello world"), "file:hello.txt")
+
+	comment, err := minisign.Verify(key.Public(), digest("hello world"), signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comment != "file:hello.txt" {
+		t.Errorf("got %q; want %q", comment, "file:hello.txt")
+	}
+
+	// tampered data
+	_, err = minisign.Verify(key.Public(), digest("hello world!"), signature)
+	if err == nil {
+		t.Errorf("expected verification failure for tampered data")
+	}
+
+	// tampered trusted comment
+	tampered := strings.Replace(string(signature), "file:hello.txt", "file:other.txt", 1)
+	_, err = minisign.Verify(key.Public(), digest("hello world"), []byte(tampered))
+	if err == nil {
+		t.Errorf("expected verification failure for tampered trusted comment")
+	}
+
+	// wrong key
+	other, err := minisign.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = minisign.Verify(other.Public(), digest("hello world"), signature)
+	if err == nil {
+		t.Errorf("expected verification failure for wrong key")
+	}
+}
+
+func TestParseCLIKeys(t *testing.T) {
+	key, err := minisign.ParsePrivateKey(cliPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ID != 0xB141866BA4568B38 {
+		t.Errorf("got %016X; want %016X", key.ID, uint64(0xB141866BA4568B38))
+	}
+
+	pub, err := minisign.ParsePublicKey(cliPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.ID != key.ID || !pub.Key.Equal(key.Public().Key) {
+		t.Errorf("public key does not match private key")
+	}
+
+	// keys are written in the same format as the minisign tool
+	want := "RWQ4i1aka4ZBsR0gESesJ6Ay57fGFJ9T1ajVmanT7MFMCCDbPZ8uqDcS"
+	if key.Public().String() != want {
+		t.Errorf("got %q; want %q", key.Public().String(), want)
+	}
+}
+
+func TestVerifyCLISignature(t *testing.T) {
+	key, err := minisign.ParsePublicKey(cliSignaturePublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comment, err := minisign.Verify(key, digest("test"), []byte(cliSignature))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "timestamp:1635443258\tfile:test\thashed"
+	if comment != want {
+		t.Errorf("got %q; want %q", comment, want)
+	}
+}
+
+func TestSignVerifyReference(t *testing.T) {
+	key, err := minisign.ParsePrivateKey(cliPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signatures made here must verify with the minisign author's Go implementation
+	signature := minisign.Sign(key, digest("hello world"), "file:hello.txt")
+
+	pub, err := reference.DecodePublicKey(cliPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := reference.DecodeSignature(string(signature))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := pub.Verify([]byte("hello world"), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Errorf("signature verification failed")
+	}
+}
@@ -0,0 +1,112 @@
+package pg2s3
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Directory format backups are packaged as tar archives (identified by the
+// "ustar" magic at offset 257 of the first header block)
+const (
+	tarMagicOffset = 257
+	tarMagic       = "ustar"
+)
+
+// Check if a backup stream is a packaged directory format backup (without consuming it)
+func isDirectoryBackup(r *bufio.Reader) bool {
+	header, _ := r.Peek(tarMagicOffset + len(tarMagic))
+	if len(header) < tarMagicOffset+len(tarMagic) {
+		return false
+	}
+
+	return bytes.Equal(header[tarMagicOffset:], []byte(tarMagic))
+}
+
+// Write the contents of a directory format backup to a tar archive
+func packDirectory(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+
+	err := tw.AddFS(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// Stream a tar archive of a directory format backup. The cleanup func is called
+// once the archive has been fully read or the stream is closed early.
+func streamDirectory(dir string, cleanup func()) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		defer cleanup()
+		w.CloseWithError(packDirectory(w, dir))
+	}()
+
+	return r
+}
+
+// Extract a tar archive of a directory format backup into an existing directory
+func unpackDirectory(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// don't allow entries to escape the target directory
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in backup: %q", header.Name)
+		}
+
+		path := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0700)
+			if err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			err = os.MkdirAll(filepath.Dir(path), 0700)
+			if err != nil {
+				return err
+			}
+
+			err = writeFile(path, tr)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported entry in backup: %q", header.Name)
+		}
+	}
+
+	return nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
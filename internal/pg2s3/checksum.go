@@ -0,0 +1,78 @@
+package pg2s3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/djherbis/buffer"
+)
+
+// Format a checksum the same way as sha256sum (so that it can be checked with "sha256sum -c")
+func formatChecksum(sum []byte, name string) string {
+	return fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum), name)
+}
+
+// Parse the hex-encoded checksum out of a line of sha256sum output
+func ParseChecksum(s string) (string, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("invalid checksum: %q", s)
+	}
+
+	sum, err := hex.DecodeString(fields[0])
+	if err != nil || len(sum) != 32 {
+		return "", fmt.Errorf("invalid checksum: %q", fields[0])
+	}
+
+	return fields[0], nil
+}
+
+// Read the checksum stored alongside an object
+func readChecksum(storage Storage, key string) (string, error) {
+	r, err := storage.Get(key + checksumSuffix)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return ParseChecksum(string(data))
+}
+
+// Read the checksum stored alongside a backup (errors wrap fs.ErrNotExist if
+// the backup predates checksums)
+func (c *Client) BackupChecksum(name string) (string, error) {
+	storage, err := c.storage()
+	if err != nil {
+		return "", err
+	}
+
+	return readChecksum(storage, c.objectKey(name))
+}
+
+// Verify a backup against its checksum. Like VerifyBackup, the backup is fully
+// buffered so that nothing downstream ever sees unverified data.
+func VerifyChecksum(backup io.Reader, want string) (io.Reader, error) {
+	// buffer 32MB to memory, after that buffer to 64MB chunked files
+	verified := buffer.NewUnboundedBuffer(32*1024*1024, 64*1024*1024)
+
+	sum := sha256.New()
+	_, err := io.Copy(io.MultiWriter(verified, sum), backup)
+	if err != nil {
+		return nil, err
+	}
+
+	got := hex.EncodeToString(sum.Sum(nil))
+	if got != want {
+		return nil, fmt.Errorf("checksum mismatch (got %s, want %s)", got, want)
+	}
+
+	return verified, nil
+}
@@ -0,0 +1,52 @@
+package pg2s3_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+func TestParseChecksum(t *testing.T) {
+	sum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	got, err := pg2s3.ParseChecksum(sum + "  pg2s3_2024-01-01T00:00:00Z.backup\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sum {
+		t.Errorf("got %q; want %q", got, sum)
+	}
+
+	invalid := []string{"", "foo  pg2s3.backup", "e3b0c442  pg2s3.backup"}
+	for _, s := range invalid {
+		_, err = pg2s3.ParseChecksum(s)
+		if err == nil {
+			t.Errorf("checksum %q should be invalid", s)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	// SHA-256 of "data"
+	sum := "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7"
+
+	verified, err := pg2s3.VerifyChecksum(strings.NewReader("data"), sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(verified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "data" {
+		t.Errorf("got %q; want %q", got, "data")
+	}
+
+	_, err = pg2s3.VerifyChecksum(strings.NewReader("corrupt"), sum)
+	if err == nil {
+		t.Errorf("corrupt data should fail verification")
+	}
+}
@@ -1,58 +1,168 @@
 package pg2s3
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"filippo.io/age"
 	"github.com/djherbis/buffer"
 	"github.com/jackc/pgx/v5"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 
 	"github.com/theandrew168/pg2s3/internal/config"
+	"github.com/theandrew168/pg2s3/internal/minisign"
 )
 
+// Metadata key used to record which recipients a backup was encrypted to
+const recipientsMetadataKey = "Recipients"
+
+// Encrypted backups begin with the age header
+const ageMagic = "age-encryption.org/v1\n"
+
+// Suffix of the sidecar object holding a backup's detached signature
+const signatureSuffix = ".minisig"
+
+// Suffix of the sidecar object holding a backup's SHA-256 checksum (in sha256sum format)
+const checksumSuffix = ".sha256"
+
 type Client struct {
 	cfg config.Config
+
+	// storage backend and destination clients (opened on first use)
+	mu           sync.Mutex
+	store        Storage
+	destinations []*Client
+}
+
+type BackupInfo struct {
+	Name         string
+	Size         int64
+	LastModified time.Time
+	// fingerprints of the public keys that the backup was encrypted to
+	Recipients []string
 }
 
 func NewClient(cfg config.Config) (*Client, error) {
-	ctx := context.Background()
+	return newClient(cfg, cfg.PGURL, true)
+}
 
-	// instantiate a pg2s3 client
-	client := &Client{
-		cfg: cfg,
-	}
+// Create a client without connecting to the database (for managing existing
+// backups, even those of databases that no longer exist)
+func NewStorageClient(cfg config.Config) (*Client, error) {
+	return newClient(cfg, "", true)
+}
 
-	// validate connection to PG
-	connPG, err := pgx.Connect(ctx, cfg.PGURL)
+// Create a client for restoring backups. The restore target is validated
+// instead of the database being backed up (which may be unavailable).
+func NewRestoreClient(cfg config.Config) (*Client, error) {
+	pgURL, err := restoreCheckURL(cfg)
 	if err != nil {
 		return nil, err
 	}
-	defer connPG.Close(ctx)
 
-	if err = connPG.Ping(ctx); err != nil {
-		return nil, err
-	}
+	return newClient(cfg, pgURL, true)
+}
 
-	// validate connection to S3
-	connS3, err := client.connectS3()
+// Create a client for restoring backups without connecting to storage (for
+// restoring local backups while storage is unavailable)
+func NewLocalClient(cfg config.Config) (*Client, error) {
+	pgURL, err := restoreCheckURL(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err = connS3.ListBuckets(ctx); err != nil {
-		return nil, err
+	return newClient(cfg, pgURL, false)
+}
+
+// Determine which database to validate before restoring: the restore target
+// or, if it may still need to be created, its server's maintenance database
+func restoreCheckURL(cfg config.Config) (string, error) {
+	pgURL := restoreURL(cfg)
+	if cfg.Restore.CreateDatabase {
+		return replaceDatabase(pgURL, maintenanceDatabase)
+	}
+
+	return pgURL, nil
+}
+
+// Create a client, validating the connections to the given database (if any)
+// and storage (if applicable)
+func newClient(cfg config.Config, pgURL string, connectStorage bool) (*Client, error) {
+	ctx := context.Background()
+
+	// instantiate a pg2s3 client
+	client := &Client{
+		cfg: cfg,
+	}
+
+	// validate connection to PG (if applicable)
+	if pgURL != "" {
+		connPG, err := pgx.Connect(ctx, pgURL)
+		if err != nil {
+			return nil, err
+		}
+		defer connPG.Close(ctx)
+
+		if err = connPG.Ping(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// validate connection to storage (if applicable)
+	if connectStorage {
+		storage, err := client.storage()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = storage.Ping(); err != nil {
+			return nil, err
+		}
+
+		// check each mirror as well so that a misconfigured destination fails now
+		// rather than halfway through the first backup
+		for _, mirror := range client.Destinations()[1:] {
+			storage, err := mirror.storage()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", mirror.Destination(), err)
+			}
+
+			if err = storage.Ping(); err != nil {
+				return nil, fmt.Errorf("%s: %w", mirror.Destination(), err)
+			}
+		}
 	}
 
 	// validate public keys (if provided)
 	for _, pubkey := range cfg.Encryption.PublicKeys {
-		_, err = age.ParseX25519Recipient(pubkey)
+		_, err := age.ParseX25519Recipient(pubkey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// validate signing keys (if provided)
+	if cfg.Signing.PrivateKey != "" {
+		_, err := minisign.ParsePrivateKey(cfg.Signing.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Signing.PublicKey != "" {
+		_, err := minisign.ParsePublicKey(cfg.Signing.PublicKey)
 		if err != nil {
 			return nil, err
 		}
@@ -61,12 +171,19 @@ func NewClient(cfg config.Config) (*Client, error) {
 	return client, nil
 }
 
-func (c *Client) CreateBackup() (io.Reader, error) {
+// Create a backup of the database. The returned backup must be closed once it
+// has been read.
+func (c *Client) CreateBackup() (io.ReadCloser, error) {
+	if c.cfg.Backup.Format == config.FormatDirectory {
+		return c.createDirectoryBackup()
+	}
+
 	// leave extra information in the back up (owners, privileges, etc) in case we ever need them
 	args := []string{
 		"-Fc", // custom output format (compressed and flexible)
-		c.cfg.PGURL,
 	}
+	args = append(args, c.dumpFilterArgs()...)
+	args = append(args, c.cfg.PGURL)
 	cmd := exec.Command("pg_dump", args...)
 
 	// buffer 32MB to memory, after that buffer to 64MB chunked files
@@ -81,38 +198,255 @@ func (c *Client) CreateBackup() (io.Reader, error) {
 		return nil, errors.New(capture.String())
 	}
 
-	return backup, nil
+	return io.NopCloser(backup), nil
+}
+
+// Create a directory format backup (which supports parallel dumps) and stream it as a tar
+// archive. The tar itself isn't compressed: pg_dump already compresses each table's data
+// file (and a second pass over compressed data would only cost CPU time). The dump is
+// removed once the archive has been read.
+func (c *Client) createDirectoryBackup() (io.ReadCloser, error) {
+	tmp, err := os.MkdirTemp("", "pg2s3-*")
+	if err != nil {
+		return nil, err
+	}
+
+	cleanup := func() {
+		os.RemoveAll(tmp)
+	}
+
+	// pg_dump requires that the output directory doesn't exist yet
+	dir := filepath.Join(tmp, "backup")
+
+	args := []string{
+		"-Fd", // directory output format (compressed and parallelizable)
+		"-f",  // output directory
+		dir,
+	}
+	if c.cfg.Backup.Jobs > 1 {
+		args = append(args, "-j", strconv.Itoa(c.cfg.Backup.Jobs))
+	}
+	args = append(args, c.dumpFilterArgs()...)
+	args = append(args, c.cfg.PGURL)
+	cmd := exec.Command("pg_dump", args...)
+
+	var capture bytes.Buffer
+	cmd.Stderr = &capture
+
+	err = cmd.Run()
+	if err != nil {
+		cleanup()
+		return nil, errors.New(capture.String())
+	}
+
+	return streamDirectory(dir, cleanup), nil
+}
+
+// pg_dump arguments for the configured schema and table filters
+func (c *Client) dumpFilterArgs() []string {
+	var args []string
+	for _, pattern := range c.cfg.Backup.Schemas {
+		args = append(args, "-n", pattern)
+	}
+	for _, pattern := range c.cfg.Backup.ExcludeSchemas {
+		args = append(args, "-N", pattern)
+	}
+	for _, pattern := range c.cfg.Backup.Tables {
+		args = append(args, "-t", pattern)
+	}
+	for _, pattern := range c.cfg.Backup.ExcludeTables {
+		args = append(args, "-T", pattern)
+	}
+	for _, pattern := range c.cfg.Backup.ExcludeTableData {
+		args = append(args, "--exclude-table-data", pattern)
+	}
+	return args
+}
+
+// Database that backups get restored into (defaults to the backed up database)
+func (c *Client) RestoreURL() string {
+	return restoreURL(c.cfg)
+}
+
+func restoreURL(cfg config.Config) string {
+	if cfg.Restore.PGURL != "" {
+		return cfg.Restore.PGURL
+	}
+	return cfg.PGURL
 }
 
 func (c *Client) RestoreBackup(backup io.Reader) error {
+	if c.cfg.Restore.Connections == config.ConnectionsBlock {
+		return c.restoreBackupBlocked(backup)
+	}
+
+	return c.restoreBackup(backup, c.RestoreURL())
+}
+
+func (c *Client) restoreBackup(backup io.Reader, pgURL string) error {
+	cmd, cleanup, err := c.restoreCommand(backup, pgURL)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var capture bytes.Buffer
+	cmd.Stderr = &capture
+
+	err = cmd.Run()
+	if err != nil {
+		return errors.New(capture.String())
+	}
+
+	return nil
+}
+
+// Build the pg_restore command for restoring a backup. The returned cleanup
+// func removes any temporary files and must be called once the command is done.
+func (c *Client) restoreCommand(backup io.Reader, pgURL string) (*exec.Cmd, func(), error) {
 	args := []string{
-		"--clean",         // clean DB object before recreating them
-		"--if-exists",     // use IF EXISTS when dropping objects
 		"--no-owner",      // skip restoration of object ownership
 		"--no-privileges", // skip restoration of access privileges (grant/revoke)
 		"-d",              // database to be restored
-		c.cfg.PGURL,
+		pgURL,
+	}
+	if !c.cfg.Restore.DataOnly {
+		// clean DB objects before recreating them (using IF EXISTS when dropping)
+		args = append(args, "--clean", "--if-exists")
+	}
+	args = append(args, c.restoreFilterArgs()...)
+
+	// restore directly from stdin when possible
+	br := bufio.NewReader(backup)
+	if !isDirectoryBackup(br) && c.cfg.Restore.Jobs <= 1 && !c.filtersTables() {
+		cmd := exec.Command("pg_restore", args...)
+		cmd.Stdin = br
+
+		cleanup := func() {}
+		return cmd, cleanup, nil
+	}
+
+	// otherwise, the backup must be available locally since it gets read more than once
+	dir, err := os.MkdirTemp("", "pg2s3-*")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		os.RemoveAll(dir)
 	}
+
+	archive, err := localArchive(br, dir)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	// parallel restores can't read from stdin
+	if c.cfg.Restore.Jobs > 1 {
+		args = append(args, "-j", strconv.Itoa(c.cfg.Restore.Jobs))
+	}
+
+	// pg_restore can't match table patterns (or exclude tables) so filter the TOC instead
+	if c.filtersTables() {
+		list, err := c.writeRestoreList(archive, dir)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+
+		args = append(args, "-L", list)
+	}
+
+	args = append(args, archive)
+	cmd := exec.Command("pg_restore", args...)
+	return cmd, cleanup, nil
+}
+
+// pg_restore arguments for the configured restore filters and modes
+func (c *Client) restoreFilterArgs() []string {
+	var args []string
 	for _, schema := range c.cfg.Restore.Schemas {
-		// if configured, specify which schemas should be restored
 		args = append(args, "-n", schema)
 	}
-	cmd := exec.Command("pg_restore", args...)
+	for _, schema := range c.cfg.Restore.ExcludeSchemas {
+		args = append(args, "-N", schema)
+	}
+	if c.cfg.Restore.DataOnly {
+		args = append(args, "--data-only")
+	}
+	if c.cfg.Restore.SchemaOnly {
+		args = append(args, "--schema-only")
+	}
+	if c.cfg.Restore.SingleTransaction {
+		args = append(args, "--single-transaction")
+	}
+	return args
+}
 
-	cmd.Stdin = backup
+// Check if the restore is limited to (or excludes) specific tables
+func (c *Client) filtersTables() bool {
+	return len(c.cfg.Restore.Tables) > 0 || len(c.cfg.Restore.ExcludeTables) > 0
+}
 
-	var capture bytes.Buffer
-	cmd.Stderr = &capture
+// Write a TOC list (for use with pg_restore -L) that only includes the selected tables
+func (c *Client) writeRestoreList(archive string, dir string) (string, error) {
+	entries, err := readTOC(archive)
+	if err != nil {
+		return "", err
+	}
 
-	err := cmd.Run()
+	objectTables, err := readObjectTables(archive)
 	if err != nil {
-		return errors.New(capture.String())
+		return "", err
 	}
 
-	return nil
+	entries = FilterTables(entries, c.cfg.Restore.Tables, c.cfg.Restore.ExcludeTables, objectTables)
+
+	var list bytes.Buffer
+	for _, entry := range entries {
+		fmt.Fprintln(&list, entry.Line)
+	}
+
+	path := filepath.Join(dir, "restore.list")
+	err = os.WriteFile(path, list.Bytes(), 0600)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Write a backup into a local directory, returning the path that pg_restore should read
+// from: either the unpacked directory (for directory format backups) or a single file.
+func localArchive(backup *bufio.Reader, dir string) (string, error) {
+	if isDirectoryBackup(backup) {
+		path := filepath.Join(dir, "backup")
+		err := os.Mkdir(path, 0700)
+		if err != nil {
+			return "", err
+		}
+
+		err = unpackDirectory(backup, path)
+		if err != nil {
+			return "", err
+		}
+
+		return path, nil
+	}
+
+	path := filepath.Join(dir, "backup.dump")
+	err := writeFile(path, backup)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
 }
 
-func (c *Client) EncryptBackup(backup io.Reader, publicKeys []string) (io.Reader, error) {
+// Encrypt a backup to the given public keys. The backup is encrypted as it is
+// read (rather than up front) and must be closed once read.
+func (c *Client) EncryptBackup(backup io.Reader, publicKeys []string) (io.ReadCloser, error) {
 	var recipients []age.Recipient
 	for _, pubkey := range publicKeys {
 		recipient, err := age.ParseX25519Recipient(pubkey)
@@ -123,66 +457,87 @@ func (c *Client) EncryptBackup(backup io.Reader, publicKeys []string) (io.Reader
 		recipients = append(recipients, recipient)
 	}
 
+	if len(recipients) == 0 {
+		return nil, errors.New("no public keys provided")
+	}
+
 	// setup encryption pipeline
-	var encrypted bytes.Buffer
-	w, err := age.Encrypt(&encrypted, recipients...)
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(encrypt(w, backup, recipients))
+	}()
+
+	return r, nil
+}
+
+func encrypt(w io.Writer, backup io.Reader, recipients []age.Recipient) error {
+	ew, err := age.Encrypt(w, recipients...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// apply encryption by copying data through
-	if _, err = io.Copy(w, backup); err != nil {
-		return nil, err
+	if _, err = io.Copy(ew, backup); err != nil {
+		return err
 	}
 
 	// explicit close to flush encryption
-	if err = w.Close(); err != nil {
-		return nil, err
-	}
+	return ew.Close()
+}
 
-	return &encrypted, nil
+// Check if a backup stream is encrypted with age (without consuming it)
+func IsEncrypted(r *bufio.Reader) bool {
+	header, _ := r.Peek(len(ageMagic))
+	return string(header) == ageMagic
 }
 
-func (c *Client) DecryptBackup(encrypted io.Reader, privateKey string) (io.Reader, error) {
-	identity, err := age.ParseX25519Identity(privateKey)
-	if err != nil {
-		return nil, err
+// Decrypt a backup using whichever of the given private keys it was encrypted to
+func (c *Client) DecryptBackup(encrypted io.Reader, privateKeys ...string) (io.Reader, error) {
+	var identities []age.Identity
+	for _, privateKey := range privateKeys {
+		identity, err := age.ParseX25519Identity(privateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, identity)
+	}
+
+	if len(identities) == 0 {
+		return nil, errors.New("no private keys provided")
 	}
 
 	// setup decryption pipeline
-	r, err := age.Decrypt(encrypted, identity)
+	r, err := age.Decrypt(encrypted, identities...)
 	if err != nil {
 		return nil, err
 	}
 
-	// apply decryption by copying data through
-	var backup bytes.Buffer
-	if _, err = io.Copy(&backup, r); err != nil {
+	// apply decryption by copying data through (the whole backup is decrypted
+	// and authenticated before any of it can be restored)
+	// buffer 32MB to memory, after that buffer to 64MB chunked files
+	backup := buffer.NewUnboundedBuffer(32*1024*1024, 64*1024*1024)
+	if _, err = io.Copy(backup, r); err != nil {
 		return nil, err
 	}
 
-	return &backup, nil
+	return backup, nil
 }
 
 func (c *Client) ListBackups() ([]string, error) {
-	client, err := c.connectS3()
+	storage, err := c.storage()
 	if err != nil {
 		return nil, err
 	}
 
-	ctx := context.Background()
-	objects := client.ListObjects(
-		ctx,
-		c.cfg.S3.BucketName,
-		minio.ListObjectsOptions{},
-	)
+	return listBackups(storage, c.cfg.S3.Prefix)
+}
 
-	var backups []string
-	for object := range objects {
-		if object.Err != nil {
-			return nil, object.Err
-		}
-		backups = append(backups, object.Key)
+// List the backups stored directly beneath a key prefix (newest first)
+func listBackups(storage Storage, prefix string) ([]string, error) {
+	backups, err := listBackupNames(storage, prefix)
+	if err != nil {
+		return nil, err
 	}
 
 	err = sortBackups(backups)
@@ -193,91 +548,321 @@ func (c *Client) ListBackups() ([]string, error) {
 	return backups, nil
 }
 
-func (c *Client) UploadBackup(name string, backup io.Reader) error {
-	client, err := c.connectS3()
+// List the names of the backups stored beneath a prefix (in no particular order)
+func listBackupNames(storage Storage, prefix string) ([]string, error) {
+	objects, err := storage.List(prefix)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	ctx := context.Background()
-	_, err = client.PutObject(
-		ctx,
-		c.cfg.S3.BucketName,
-		name,
-		backup,
-		-1,
-		minio.PutObjectOptions{},
-	)
+	var backups []string
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, prefix)
+
+		// skip "directories" (such as those of other databases)
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+
+		// skip sidecar objects and companion globals backups
+		if strings.HasSuffix(name, signatureSuffix) || strings.HasSuffix(name, checksumSuffix) || isGlobalsName(name) {
+			continue
+		}
+
+		backups = append(backups, name)
+	}
+
+	return backups, nil
+}
+
+// Names of the objects stored alongside a backup (which may or may not exist)
+func companionNames(name string) []string {
+	return []string{
+		name + signatureSuffix,
+		name + checksumSuffix,
+		GlobalsName(name),
+		GlobalsName(name) + signatureSuffix,
+		GlobalsName(name) + checksumSuffix,
+	}
+}
+
+// Upload a backup, recording the fingerprints of any public keys it was encrypted to
+// and storing its checksum and a detached signature (if a signing key is configured)
+// alongside it. The backup is streamed to every destination at once. If only some
+// uploads fail, a *PartialUploadError is returned.
+func (c *Client) UploadBackup(name string, backup io.Reader, publicKeys []string) error {
+	// checksum the backup as it is uploaded
+	sum := sha256.New()
+	backup = io.TeeReader(backup, sum)
+
+	// hash the backup for signing as well (if applicable)
+	hash := minisign.NewHash()
+	if c.cfg.Signing.PrivateKey != "" {
+		backup = io.TeeReader(backup, hash)
+	}
+
+	metadata := make(map[string]string)
+	if len(publicKeys) > 0 {
+		var fingerprints []string
+		for _, pubkey := range publicKeys {
+			fingerprints = append(fingerprints, RecipientFingerprint(pubkey))
+		}
+		metadata[recipientsMetadataKey] = strings.Join(fingerprints, ",")
+	}
+
+	destinations := c.Destinations()
+
+	var errs []error
+	if len(destinations) == 1 {
+		errs = []error{c.putObject(c.objectKey(name), backup, -1, metadata)}
+	} else {
+		var err error
+		errs, err = fanOut(backup, destinations, func(d *Client, r io.Reader) error {
+			return d.putObject(d.objectKey(name), r, -1, metadata)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// store the checksum wherever the backup was uploaded successfully
+	checksum := formatChecksum(sum.Sum(nil), name)
+	for i, d := range destinations {
+		if errs[i] != nil {
+			continue
+		}
+
+		errs[i] = d.putObject(d.objectKey(name)+checksumSuffix, strings.NewReader(checksum), int64(len(checksum)), nil)
+	}
+
+	// store the detached signature wherever the backup was uploaded successfully
+	if c.cfg.Signing.PrivateKey != "" {
+		key, err := minisign.ParsePrivateKey(c.cfg.Signing.PrivateKey)
+		if err != nil {
+			return err
+		}
+
+		// bind the signature to this backup's name so that it can't be reused for another
+		comment := fmt.Sprintf("timestamp:%d\tfile:%s\thashed", time.Now().Unix(), c.objectKey(name))
+		signature := minisign.Sign(key, hash.Sum(nil), comment)
+
+		for i, d := range destinations {
+			if errs[i] != nil {
+				continue
+			}
+
+			errs[i] = d.putObject(d.objectKey(name)+signatureSuffix, bytes.NewReader(signature), int64(len(signature)), nil)
+		}
+	}
+
+	var failed []DestinationError
+	for i, d := range destinations {
+		if errs[i] != nil {
+			failed = append(failed, DestinationError{Destination: d.Destination(), Err: errs[i]})
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	if len(failed) == len(destinations) {
+		return errors.Join(errs...)
+	}
+	return &PartialUploadError{Errors: failed}
+}
+
+func (c *Client) putObject(key string, r io.Reader, size int64, metadata map[string]string) error {
+	storage, err := c.storage()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return storage.Put(key, r, size, metadata)
 }
 
-func (c *Client) DownloadBackup(name string) (io.Reader, error) {
-	client, err := c.connectS3()
+// Verify a downloaded backup against its detached signature. The backup is
+// fully buffered during verification so that nothing downstream ever sees
+// unverified data. Backups without a valid signature are rejected.
+func (c *Client) VerifyBackup(name string, backup io.Reader) (io.Reader, error) {
+	if c.cfg.Signing.PublicKey == "" {
+		return nil, errors.New("no signing public key configured")
+	}
+
+	storage, err := c.storage()
 	if err != nil {
 		return nil, err
 	}
 
-	ctx := context.Background()
-	backup, err := client.GetObject(
-		ctx,
-		c.cfg.S3.BucketName,
-		name,
-		minio.GetObjectOptions{},
-	)
+	object, err := storage.Get(c.objectKey(name) + signatureSuffix)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s is not signed", name)
+		}
 		return nil, err
 	}
+	defer object.Close()
 
-	return backup, nil
+	signature, err := io.ReadAll(object)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s is not signed", name)
+		}
+		return nil, err
+	}
+
+	verified, signedKey, err := c.VerifySignature(backup, signature)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	// ensure the signature was made for this specific backup
+	if signedKey != c.objectKey(name) {
+		return nil, fmt.Errorf("%s: signature belongs to a different backup", name)
+	}
+
+	return verified, nil
 }
 
-func (c *Client) DeleteBackup(name string) error {
-	client, err := c.connectS3()
+// Verify a backup against a detached signature, returning the (fully buffered)
+// backup along with the key of the backup that the signature was made for
+func (c *Client) VerifySignature(backup io.Reader, signature []byte) (io.Reader, string, error) {
+	if c.cfg.Signing.PublicKey == "" {
+		return nil, "", errors.New("no signing public key configured")
+	}
+
+	key, err := minisign.ParsePublicKey(c.cfg.Signing.PublicKey)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
-	ctx := context.Background()
-	err = client.RemoveObject(
-		ctx,
-		c.cfg.S3.BucketName,
-		name,
-		minio.RemoveObjectOptions{},
-	)
+	// buffer 32MB to memory, after that buffer to 64MB chunked files
+	verified := buffer.NewUnboundedBuffer(32*1024*1024, 64*1024*1024)
+
+	hash := minisign.NewHash()
+	_, err = io.Copy(io.MultiWriter(verified, hash), backup)
+	if err != nil {
+		return nil, "", err
+	}
+
+	comment, err := minisign.Verify(key, hash.Sum(nil), signature)
+	if err != nil {
+		return nil, "", err
+	}
+
+	signedKey := ""
+	for _, field := range strings.Split(comment, "\t") {
+		if strings.HasPrefix(field, "file:") {
+			signedKey = strings.TrimPrefix(field, "file:")
+		}
+	}
+
+	return verified, signedKey, nil
+}
+
+func (c *Client) StatBackup(name string) (BackupInfo, error) {
+	storage, err := c.storage()
+	if err != nil {
+		return BackupInfo{}, err
+	}
+
+	object, err := storage.Stat(c.objectKey(name))
+	if err != nil {
+		return BackupInfo{}, err
+	}
+
+	info := BackupInfo{
+		Name:         name,
+		Size:         object.Size,
+		LastModified: object.LastModified,
+	}
+
+	// metadata keys are canonicalized differently by different providers
+	for key, value := range object.Metadata {
+		if strings.EqualFold(key, recipientsMetadataKey) && value != "" {
+			info.Recipients = strings.Split(value, ",")
+		}
+	}
+
+	return info, nil
+}
+
+// Download a backup. The returned backup must be closed once it has been read.
+func (c *Client) DownloadBackup(name string) (io.ReadCloser, error) {
+	storage, err := c.storage()
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.Get(c.objectKey(name))
+}
+
+func (c *Client) DeleteBackup(name string) error {
+	storage, err := c.storage()
 	if err != nil {
 		return err
 	}
 
+	// remove the backup along with its sidecars and any globals backup (and its sidecars)
+	for _, key := range append([]string{name}, companionNames(name)...) {
+		// no error if it doesn't exist
+		err = storage.Delete(c.objectKey(key))
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (c *Client) connectS3() (*minio.Client, error) {
-	creds := credentials.NewStaticV4(
-		c.cfg.S3.AccessKeyID,
-		c.cfg.S3.SecretAccessKey,
-		"",
-	)
+// List the "directories" directly beneath the key prefix (each holding the
+// backups of a single database when backing up all databases on a server)
+func (c *Client) ListBackupDirectories() ([]string, error) {
+	storage, err := c.storage()
+	if err != nil {
+		return nil, err
+	}
+
+	return listDirectories(storage, c.cfg.S3.Prefix)
+}
 
-	// disable HTTPS requirement for local development / testing
-	secure := true
-	if strings.Contains(c.cfg.S3.Endpoint, "localhost") {
-		secure = false
+// List the "directories" stored directly beneath a key prefix (sorted by name)
+func listDirectories(storage Storage, prefix string) ([]string, error) {
+	objects, err := storage.List(prefix)
+	if err != nil {
+		return nil, err
 	}
-	if strings.Contains(c.cfg.S3.Endpoint, "127.0.0.1") {
-		secure = false
+
+	var directories []string
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, prefix)
+		if strings.HasSuffix(name, "/") {
+			directories = append(directories, strings.TrimSuffix(name, "/"))
+		}
 	}
 
-	client, err := minio.New(c.cfg.S3.Endpoint, &minio.Options{
-		Creds:  creds,
-		Secure: secure,
-	})
+	sort.Strings(directories)
+	return directories, nil
+}
+
+// Full object key for a backup (or one of its companion objects)
+func (c *Client) objectKey(name string) string {
+	return c.cfg.S3.Prefix + name
+}
+
+// Open the storage backend that backups are kept in (reused by later operations)
+func (c *Client) storage() (Storage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.store != nil {
+		return c.store, nil
+	}
+
+	store, err := NewStorage(c.cfg.S3, c.cfg.SFTP)
 	if err != nil {
 		return nil, err
 	}
 
-	return client, nil
+	c.store = store
+	return store, nil
 }
@@ -1,9 +1,17 @@
 package pg2s3_test
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 
@@ -51,6 +59,132 @@ func createBucket(cfg config.Config) error {
 	return nil
 }
 
+// Point a connection URL at a different database
+func databaseURL(t *testing.T, pgURL string, database string) string {
+	t.Helper()
+
+	u, err := url.Parse(pgURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u.Path = "/" + database
+	return u.String()
+}
+
+// Run statements against a database
+func execSQL(t *testing.T, pgURL string, statements ...string) {
+	t.Helper()
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, pgURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	for _, stmt := range statements {
+		_, err = conn.Exec(ctx, stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Run a query that returns a single value
+func queryValue[T any](t *testing.T, pgURL string, query string, args ...any) T {
+	t.Helper()
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, pgURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	var value T
+	err = conn.QueryRow(ctx, query, args...).Scan(&value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+// Create a scratch database (containing a "widgets" table with two rows) and
+// return its name along with the local development config pointed at it. The
+// database, along with any others named after it, is dropped once the test completes.
+func createTestDatabase(t *testing.T) (config.Config, string) {
+	t.Helper()
+
+	// read the local development config file
+	cfg, err := config.ReadFile("../../pg2s3.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = createBucket(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// scratch databases are restored in full
+	cfg.Restore.Schemas = nil
+
+	database := fmt.Sprintf("pg2s3_test_%d", time.Now().UnixNano())
+	execSQL(t, cfg.PGURL, "CREATE DATABASE "+database)
+
+	maintenanceURL := cfg.PGURL
+	t.Cleanup(func() {
+		ctx := context.Background()
+		conn, err := pgx.Connect(ctx, maintenanceURL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close(ctx)
+
+		rows, err := conn.Query(ctx, "SELECT datname FROM pg_database WHERE datname LIKE $1", database+"%")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		databases, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		for _, name := range databases {
+			_, err = conn.Exec(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()+" WITH (FORCE)")
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	cfg, err = pg2s3.DatabaseConfig(cfg, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execSQL(
+		t,
+		cfg.PGURL,
+		"CREATE TABLE widgets (id serial PRIMARY KEY, name text NOT NULL)",
+		"INSERT INTO widgets (name) VALUES ('foo'), ('bar')",
+	)
+
+	return cfg, database
+}
+
+// Count the widgets within a database
+func countWidgets(t *testing.T, pgURL string) int {
+	t.Helper()
+	return queryValue[int](t, pgURL, "SELECT count(*) FROM widgets")
+}
+
 func TestBackup(t *testing.T) {
 	// read the local development config file
 	cfg, err := config.ReadFile("../../pg2s3.conf")
@@ -69,16 +203,19 @@ func TestBackup(t *testing.T) {
 	}
 
 	// generate name for backup
-	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix)
+	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix, cfg.Backup.Format, time.Now())
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// create backup
-	backup, err := client.CreateBackup()
+	created, err := client.CreateBackup()
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer created.Close()
+
+	var backup io.Reader = created
 
 	// encrypt backup (if applicable)
 	if len(cfg.Encryption.PublicKeys) > 0 {
@@ -91,7 +228,7 @@ func TestBackup(t *testing.T) {
 	}
 
 	// upload backup
-	err = client.UploadBackup(name, backup)
+	err = client.UploadBackup(name, backup, cfg.Encryption.PublicKeys)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -115,16 +252,19 @@ func TestRestore(t *testing.T) {
 	}
 
 	// generate name for backup
-	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix)
+	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix, cfg.Backup.Format, time.Now())
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// create backup
-	backup, err := client.CreateBackup()
+	created, err := client.CreateBackup()
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer created.Close()
+
+	var backup io.Reader = created
 
 	// encrypt backup (if applicable)
 	if len(cfg.Encryption.PublicKeys) > 0 {
@@ -137,7 +277,7 @@ func TestRestore(t *testing.T) {
 	}
 
 	// upload backup
-	err = client.UploadBackup(name, backup)
+	err = client.UploadBackup(name, backup, cfg.Encryption.PublicKeys)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -194,16 +334,19 @@ func TestPrune(t *testing.T) {
 	}
 
 	// generate name for backup
-	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix)
+	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix, cfg.Backup.Format, time.Now())
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// create backup
-	backup, err := client.CreateBackup()
+	created, err := client.CreateBackup()
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer created.Close()
+
+	var backup io.Reader = created
 
 	// encrypt backup (if applicable)
 	if len(cfg.Encryption.PublicKeys) > 0 {
@@ -216,7 +359,7 @@ func TestPrune(t *testing.T) {
 	}
 
 	// upload backup
-	err = client.UploadBackup(name, backup)
+	err = client.UploadBackup(name, backup, cfg.Encryption.PublicKeys)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -235,3 +378,129 @@ func TestPrune(t *testing.T) {
 		}
 	}
 }
+
+func TestImportBackup(t *testing.T) {
+	// read the local development config file
+	cfg, err := config.ReadFile("../../pg2s3.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create a custom format dump outside of pg2s3
+	cfg.Backup.Format = config.FormatCustom
+	client, err := pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := client.CreateBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	path := filepath.Join(t.TempDir(), "legacy.dump")
+	data, err := io.ReadAll(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	format, imported, err := client.ImportBackup(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Close()
+
+	if format != config.FormatCustom {
+		t.Errorf("got %q; want %q", format, config.FormatCustom)
+	}
+
+	got, err := io.ReadAll(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("imported dump doesn't match the original")
+	}
+
+	// plain SQL dumps are rejected
+	path = filepath.Join(t.TempDir(), "legacy.sql")
+	err = os.WriteFile(path, []byte("SELECT 1;\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = client.ImportBackup(path)
+	if err == nil {
+		t.Errorf("plain SQL dump %q should be invalid", path)
+	}
+}
+
+func TestDirectoryRoundTrip(t *testing.T) {
+	cfg, _ := createTestDatabase(t)
+	cfg.Backup.Format = config.FormatDirectory
+	cfg.Backup.Jobs = 2
+
+	client, err := pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix, cfg.Backup.Format, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name = name + ".age"
+
+	created, err := client.CreateBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer created.Close()
+
+	encrypted, err := client.EncryptBackup(created, cfg.Encryption.PublicKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encrypted.Close()
+
+	err = client.UploadBackup(name, encrypted, cfg.Encryption.PublicKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		err := client.DeleteBackup(name)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	// diverge the database from the backup
+	execSQL(t, cfg.PGURL, "INSERT INTO widgets (name) VALUES ('baz')")
+
+	downloaded, err := client.DownloadBackup(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer downloaded.Close()
+
+	backup, err := client.DecryptBackup(downloaded, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.RestoreBackup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := countWidgets(t, cfg.PGURL)
+	if count != 2 {
+		t.Errorf("got %d; want %d", count, 2)
+	}
+}
@@ -0,0 +1,185 @@
+package pg2s3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+)
+
+// A storage backend along with the key prefix that backups are kept under
+type Location struct {
+	Storage Storage
+	Prefix  string
+}
+
+// Open the location described by a storage URL (s3://, file://, or sftp://)
+func OpenLocation(url string, sftp config.SFTP) (Location, error) {
+	location, err := config.ParseS3URL(url)
+	if err != nil {
+		return Location{}, err
+	}
+
+	storage, err := NewStorage(location, sftp)
+	if err != nil {
+		return Location{}, err
+	}
+
+	err = storage.Ping()
+	if err != nil {
+		return Location{}, err
+	}
+
+	l := Location{
+		Storage: storage,
+		Prefix:  location.Prefix,
+	}
+	return l, nil
+}
+
+// List the backups within a location (newest first), optionally limited to
+// those taken within a time range (zero times are unbounded). Backups whose
+// names don't include a timestamp come last when unlimited. Otherwise, they're
+// skipped and returned separately.
+func (l Location) ListBackups(since time.Time, until time.Time) ([]string, []string, error) {
+	names, err := listBackupNames(l.Storage, l.Prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var backups, unknown []string
+	for _, name := range names {
+		_, err := ParseBackupTimestamp(name)
+		if err != nil {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		backups = append(backups, name)
+	}
+
+	err = sortBackups(backups)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if since.IsZero() && until.IsZero() {
+		return append(backups, unknown...), nil, nil
+	}
+
+	var filtered []string
+	for _, backup := range backups {
+		timestamp, err := ParseBackupTimestamp(backup)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !since.IsZero() && timestamp.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !timestamp.Before(until) {
+			continue
+		}
+
+		filtered = append(filtered, backup)
+	}
+
+	return filtered, unknown, nil
+}
+
+// List the "directories" directly beneath a location (such as those holding
+// the backups of each database when backing up all databases)
+func (l Location) ListDirectories() ([]string, error) {
+	return listDirectories(l.Storage, l.Prefix)
+}
+
+// Narrow a location down to one of its directories
+func (l Location) Directory(name string) Location {
+	l.Prefix += name + "/"
+	return l
+}
+
+// Implemented by storage backends that can copy objects without downloading them
+type serverSideCopier interface {
+	CopyFrom(from Storage, fromKey string, key string) (bool, error)
+}
+
+// Copy a backup (along with its sidecars and globals backup) between locations,
+// preserving its name and metadata. Objects that already exist at the
+// destination are skipped. Returns whether anything was copied.
+func CopyBackup(from Location, to Location, name string) (bool, error) {
+	copied := false
+	for _, key := range append([]string{name}, companionNames(name)...) {
+		_, err := to.Storage.Stat(to.Prefix + key)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return copied, err
+		}
+
+		object, err := from.Storage.Stat(from.Prefix + key)
+		if err != nil {
+			// sidecars and globals backups are optional
+			if key != name && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return copied, err
+		}
+
+		err = copyObject(from, to, key, object)
+		if err != nil {
+			return copied, err
+		}
+
+		copied = true
+	}
+
+	return copied, nil
+}
+
+// Copy a single object, server-side if possible and streamed otherwise. Streamed
+// copies are checked against the object's checksum (if it has one).
+func copyObject(from Location, to Location, key string, object Object) error {
+	if copier, ok := to.Storage.(serverSideCopier); ok {
+		handled, err := copier.CopyFrom(from.Storage, from.Prefix+key, to.Prefix+key)
+		if handled {
+			return err
+		}
+	}
+
+	want, err := readChecksum(from.Storage, from.Prefix+key)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	r, err := from.Storage.Get(from.Prefix + key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	sum := sha256.New()
+	err = to.Storage.Put(to.Prefix+key, io.TeeReader(r, sum), object.Size, object.Metadata)
+	if err != nil {
+		return err
+	}
+
+	// remove the copy if it doesn't match the original
+	got := hex.EncodeToString(sum.Sum(nil))
+	if want != "" && got != want {
+		err = to.Storage.Delete(to.Prefix + key)
+		if err != nil {
+			return err
+		}
+
+		return fmt.Errorf("%s: checksum mismatch (got %s, want %s)", key, got, want)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package pg2s3_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+// Store a backup along with its checksum
+func putBackup(t *testing.T, location pg2s3.Location, name string, data string) {
+	t.Helper()
+
+	metadata := map[string]string{"Recipients": "foo"}
+	err := location.Storage.Put(location.Prefix+name, strings.NewReader(data), int64(len(data)), metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(data))
+	checksum := hex.EncodeToString(sum[:]) + "  " + name + "\n"
+	err = location.Storage.Put(location.Prefix+name+".sha256", strings.NewReader(checksum), int64(len(checksum)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyBackup(t *testing.T) {
+	from := pg2s3.Location{Storage: pg2s3.NewFileStorage(t.TempDir()), Prefix: "prod/"}
+	to := pg2s3.Location{Storage: pg2s3.NewFileStorage(t.TempDir())}
+
+	putBackup(t, from, "pg2s3_2024-01-01T00:00:00Z.backup", "old")
+	putBackup(t, from, "pg2s3_2024-02-01T00:00:00Z.backup", "new")
+	putBackup(t, from, "pg2s3_manual.backup", "manual")
+
+	since := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	backups, skipped, err := from.ListBackups(since, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"pg2s3_2024-02-01T00:00:00Z.backup"}
+	if !reflect.DeepEqual(backups, want) {
+		t.Fatalf("got %q; want %q", backups, want)
+	}
+
+	// backups without a timestamp can't be filtered by time
+	want = []string{"pg2s3_manual.backup"}
+	if !reflect.DeepEqual(skipped, want) {
+		t.Errorf("got %q; want %q", skipped, want)
+	}
+
+	// but they're still listed when no time range is given
+	all, skipped, err := from.ListBackups(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = []string{"pg2s3_2024-02-01T00:00:00Z.backup", "pg2s3_2024-01-01T00:00:00Z.backup", "pg2s3_manual.backup"}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("got %q; want %q", all, want)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("got %q; want none skipped", skipped)
+	}
+
+	copied, err := pg2s3.CopyBackup(from, to, backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !copied {
+		t.Errorf("got %v; want %v", copied, true)
+	}
+
+	// names and metadata are preserved
+	object, err := to.Storage.Stat(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.Metadata["Recipients"] != "foo" {
+		t.Errorf("got %q; want %q", object.Metadata["Recipients"], "foo")
+	}
+
+	r, err := to.Storage.Get(backups[0] + ".sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	checksum, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(checksum), "  "+backups[0]+"\n") {
+		t.Errorf("got %q; want checksum of %q", checksum, backups[0])
+	}
+
+	// existing objects are skipped
+	copied, err = pg2s3.CopyBackup(from, to, backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied {
+		t.Errorf("got %v; want %v", copied, false)
+	}
+}
+
+func TestCopyBackupChecksumMismatch(t *testing.T) {
+	from := pg2s3.Location{Storage: pg2s3.NewFileStorage(t.TempDir())}
+	to := pg2s3.Location{Storage: pg2s3.NewFileStorage(t.TempDir())}
+
+	name := "pg2s3_2024-01-01T00:00:00Z.backup"
+	putBackup(t, from, name, "data")
+
+	// corrupt the backup after its checksum was recorded
+	err := from.Storage.Put(name, strings.NewReader("corrupt"), -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pg2s3.CopyBackup(from, to, name)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("got %v; want checksum mismatch", err)
+	}
+
+	// the corrupt copy is removed
+	_, err = to.Storage.Stat(name)
+	if err == nil {
+		t.Errorf("corrupt copy of %q should have been removed", name)
+	}
+}
+
+func TestListDirectories(t *testing.T) {
+	from := pg2s3.Location{Storage: pg2s3.NewFileStorage(t.TempDir()), Prefix: "prod/"}
+
+	putBackup(t, from, "app/pg2s3_2024-01-01T00:00:00Z.backup", "app")
+	putBackup(t, from, "web/pg2s3_2024-01-01T00:00:00Z.backup", "web")
+
+	databases, err := from.ListDirectories()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"app", "web"}
+	if !reflect.DeepEqual(databases, want) {
+		t.Fatalf("got %q; want %q", databases, want)
+	}
+
+	// backups of each database are listed beneath its directory
+	backups, _, err := from.Directory("app").ListBackups(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = []string{"pg2s3_2024-01-01T00:00:00Z.backup"}
+	if !reflect.DeepEqual(backups, want) {
+		t.Errorf("got %q; want %q", backups, want)
+	}
+}
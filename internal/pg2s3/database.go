@@ -0,0 +1,476 @@
+package pg2s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+)
+
+// Database used for administrative connections (creating databases, etc)
+const maintenanceDatabase = "postgres"
+
+// Maximum length of a PostgreSQL identifier (NAMEDATALEN - 1)
+const maxIdentifierLength = 63
+
+// Point a PostgreSQL connection string (URL or key/value format) at a different database
+func replaceDatabase(pgURL string, database string) (string, error) {
+	if strings.HasPrefix(pgURL, "postgres://") || strings.HasPrefix(pgURL, "postgresql://") {
+		u, err := url.Parse(pgURL)
+		if err != nil {
+			return "", err
+		}
+
+		u.Path = "/" + database
+		u.RawPath = ""
+		return u.String(), nil
+	}
+
+	// later keywords take precedence in key/value connection strings
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(database)
+	return fmt.Sprintf("%s dbname='%s'", pgURL, value), nil
+}
+
+// Set the application name of a PostgreSQL connection string (URL or key/value
+// format). Unlike PGAPPNAME, this can't be overridden by the string itself.
+func replaceApplicationName(pgURL string, name string) (string, error) {
+	if strings.HasPrefix(pgURL, "postgres://") || strings.HasPrefix(pgURL, "postgresql://") {
+		u, err := url.Parse(pgURL)
+		if err != nil {
+			return "", err
+		}
+
+		query := u.Query()
+		query.Set("application_name", name)
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	// later keywords take precedence in key/value connection strings
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(name)
+	return fmt.Sprintf("%s application_name='%s'", pgURL, value), nil
+}
+
+// Derive the name of a related database, truncating the base name if necessary
+func relatedDatabase(database string, suffix string) string {
+	limit := maxIdentifierLength - len(suffix)
+	if len(database) > limit {
+		database = database[:limit]
+	}
+	return database + suffix
+}
+
+// Connect to the maintenance database on the same server as the given database.
+// The name of the given database is returned alongside the connection.
+func connectMaintenance(ctx context.Context, pgURL string) (*pgx.Conn, string, error) {
+	cfg, err := pgx.ParseConfig(pgURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	database := cfg.Database
+	cfg.Database = maintenanceDatabase
+
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return conn, database, nil
+}
+
+func databaseExists(ctx context.Context, conn *pgx.Conn, database string) (bool, error) {
+	var exists bool
+	err := conn.QueryRow(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)",
+		database,
+	).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// quote a string literal for use in statements that don't accept parameters
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Create the restore target database if it doesn't already exist. Returns
+// whether or not the database was created.
+func (c *Client) CreateDatabase() (bool, error) {
+	ctx := context.Background()
+
+	conn, database, err := connectMaintenance(ctx, c.RestoreURL())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(ctx)
+
+	exists, err := databaseExists(ctx, conn, database)
+	if err != nil {
+		return false, err
+	}
+
+	if exists {
+		return false, nil
+	}
+
+	err = c.createDatabase(ctx, conn, database)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (c *Client) createDatabase(ctx context.Context, conn *pgx.Conn, database string) error {
+	// CREATE DATABASE doesn't support parameters so build the statement by hand
+	stmt := "CREATE DATABASE " + pgx.Identifier{database}.Sanitize()
+	if c.cfg.Restore.Owner != "" {
+		stmt += " OWNER " + pgx.Identifier{c.cfg.Restore.Owner}.Sanitize()
+	}
+	if c.cfg.Restore.Encoding != "" {
+		stmt += " ENCODING " + quoteLiteral(c.cfg.Restore.Encoding)
+	}
+	if c.cfg.Restore.Template != "" {
+		stmt += " TEMPLATE " + pgx.Identifier{c.cfg.Restore.Template}.Sanitize()
+	}
+
+	_, err := conn.Exec(ctx, stmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func dropDatabase(ctx context.Context, conn *pgx.Conn, database string) error {
+	_, err := conn.Exec(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{database}.Sanitize())
+	return err
+}
+
+// Terminate all other sessions connected to a database, returning how many were terminated
+func terminateConnections(ctx context.Context, conn *pgx.Conn, database string) (int, error) {
+	rows, err := conn.Query(
+		ctx,
+		`SELECT pg_terminate_backend(pid)
+		FROM pg_stat_activity
+		WHERE datname = $1 AND pid <> pg_backend_pid()`,
+		database,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	results, err := pgx.CollectRows(rows, pgx.RowTo[bool])
+	if err != nil {
+		return 0, err
+	}
+
+	terminated := 0
+	for _, ok := range results {
+		if ok {
+			terminated++
+		}
+	}
+
+	return terminated, nil
+}
+
+// Count the tables within a database (excluding system schemas)
+func countTables(ctx context.Context, pgURL string) (int, error) {
+	conn, err := pgx.Connect(ctx, pgURL)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(ctx)
+
+	var count int
+	err = conn.QueryRow(
+		ctx,
+		`SELECT count(*)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p')
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND n.nspname NOT LIKE 'pg_toast%'`,
+	).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Restore a backup without touching the live database until the very end. The
+// backup is restored into a temporary database and validated. Then, connections
+// to the live database are blocked and terminated and the two databases are
+// swapped via renames within a single transaction. The previous live database is
+// kept around (renamed with an "_old" suffix) for rollback and its name is returned.
+// If the live database doesn't exist (and restore.create_database is set), the
+// temporary database is simply renamed into place and no name is returned.
+func (c *Client) SwapRestoreBackup(backup io.Reader) (string, error) {
+	ctx := context.Background()
+
+	conn, live, err := connectMaintenance(ctx, c.RestoreURL())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(ctx)
+
+	// the swap itself happens over a connection to the maintenance database
+	if live == maintenanceDatabase {
+		return "", fmt.Errorf("database %q can't be swapped, use a different restore mode", live)
+	}
+
+	temp := relatedDatabase(live, "_restore")
+	old := relatedDatabase(live, "_old")
+
+	// check for the live database before spending time on the restore
+	liveExists, err := databaseExists(ctx, conn, live)
+	if err != nil {
+		return "", err
+	}
+	if !liveExists && !c.cfg.Restore.CreateDatabase {
+		return "", fmt.Errorf("database %q does not exist", live)
+	}
+
+	// refuse to clobber the rollback copy from a previous swap
+	exists, err := databaseExists(ctx, conn, old)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("database %q already exists, drop it before swapping again", old)
+	}
+
+	// clean up any leftovers from a previously failed swap
+	err = dropDatabase(ctx, conn, temp)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.createDatabase(ctx, conn, temp)
+	if err != nil {
+		return "", err
+	}
+
+	tempURL, err := replaceDatabase(c.RestoreURL(), temp)
+	if err != nil {
+		return "", err
+	}
+
+	// restore into and then validate the temporary database
+	err = c.restoreBackup(backup, tempURL)
+	if err == nil {
+		var count int
+		count, err = countTables(ctx, tempURL)
+		if err == nil && count == 0 {
+			err = errors.New("restored database contains no tables")
+		}
+	}
+	if err != nil {
+		dropErr := dropDatabase(ctx, conn, temp)
+		return "", errors.Join(err, dropErr)
+	}
+
+	// nothing to swap with, so just move the temporary database into place
+	if !liveExists {
+		_, err = conn.Exec(ctx, fmt.Sprintf(
+			"ALTER DATABASE %s RENAME TO %s",
+			pgx.Identifier{temp}.Sanitize(),
+			pgx.Identifier{live}.Sanitize(),
+		))
+		if err != nil {
+			return "", err
+		}
+
+		return "", nil
+	}
+
+	err = swapDatabases(ctx, conn, live, temp, old)
+	if err != nil {
+		return "", err
+	}
+
+	return old, nil
+}
+
+// Rename the live database out of the way and the temp database into its
+// place. Renames fail if anyone else is connected to the live database, so
+// new connections are blocked before terminating the existing ones (otherwise
+// clients could reconnect in between). The previous value of ALLOW_CONNECTIONS
+// is restored afterwards (on the old database if the swap succeeded).
+func swapDatabases(ctx context.Context, conn *pgx.Conn, live, temp, old string) error {
+	var allowConnections bool
+	err := conn.QueryRow(
+		ctx,
+		"SELECT datallowconn FROM pg_database WHERE datname = $1",
+		live,
+	).Scan(&allowConnections)
+	if err != nil {
+		return err
+	}
+
+	err = setAllowConnections(ctx, conn, live, false)
+	if err != nil {
+		return err
+	}
+
+	err = renameDatabases(ctx, conn, live, temp, old)
+	if err != nil {
+		return errors.Join(err, setAllowConnections(ctx, conn, live, allowConnections))
+	}
+
+	return setAllowConnections(ctx, conn, old, allowConnections)
+}
+
+func setAllowConnections(ctx context.Context, conn *pgx.Conn, database string, allow bool) error {
+	_, err := conn.Exec(ctx, allowConnectionsStatement(database, allow))
+	return err
+}
+
+// ALTER DATABASE doesn't support parameters so build the statement by hand
+func allowConnectionsStatement(database string, allow bool) string {
+	return fmt.Sprintf(
+		"ALTER DATABASE %s WITH ALLOW_CONNECTIONS %t",
+		pgx.Identifier{database}.Sanitize(),
+		allow,
+	)
+}
+
+func renameDatabases(ctx context.Context, conn *pgx.Conn, live, temp, old string) error {
+	_, err := terminateConnections(ctx, conn, live)
+	if err != nil {
+		return err
+	}
+
+	// swap the databases (renames are transactional unlike CREATE / DROP DATABASE)
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(
+		"ALTER DATABASE %s RENAME TO %s",
+		pgx.Identifier{live}.Sanitize(),
+		pgx.Identifier{old}.Sanitize(),
+	))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(
+		"ALTER DATABASE %s RENAME TO %s",
+		pgx.Identifier{temp}.Sanitize(),
+		pgx.Identifier{live}.Sanitize(),
+	))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// List the databases on the server that should be backed up (according to
+// the backup.databases and backup.exclude_databases patterns)
+func (c *Client) ListDatabases() ([]string, error) {
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, c.cfg.PGURL)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(
+		ctx,
+		`SELECT datname
+		FROM pg_database
+		WHERE datallowconn AND NOT datistemplate
+		ORDER BY datname`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	databases, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	return FilterDatabases(databases, c.cfg.Backup.Databases, c.cfg.Backup.ExcludeDatabases), nil
+}
+
+// Filter database names by include patterns (all if empty) and exclude patterns
+func FilterDatabases(databases []string, include []string, exclude []string) []string {
+	matches := func(patterns []string, database string) bool {
+		for _, pattern := range patterns {
+			ok, _ := path.Match(pattern, database)
+			if ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	var filtered []string
+	for _, database := range databases {
+		if len(include) > 0 && !matches(include, database) {
+			continue
+		}
+		if matches(exclude, database) {
+			continue
+		}
+
+		filtered = append(filtered, database)
+	}
+
+	return filtered
+}
+
+// Scope a config to a single database on the server: its connection strings
+// point at the database and its backups are stored beneath a "<database>/" prefix
+func DatabaseConfig(cfg config.Config, database string) (config.Config, error) {
+	if strings.Contains(database, "/") {
+		return config.Config{}, fmt.Errorf("invalid database name: %q", database)
+	}
+
+	pgURL, err := replaceDatabase(cfg.PGURL, database)
+	if err != nil {
+		return config.Config{}, err
+	}
+	cfg.PGURL = pgURL
+
+	if cfg.Restore.PGURL != "" {
+		restoreURL, err := replaceDatabase(cfg.Restore.PGURL, database)
+		if err != nil {
+			return config.Config{}, err
+		}
+		cfg.Restore.PGURL = restoreURL
+	}
+
+	cfg.S3.Prefix += database + "/"
+
+	// copy the mirrors so that the original config is left untouched
+	mirrors := make([]config.S3, len(cfg.Mirrors))
+	for i, mirror := range cfg.Mirrors {
+		mirror.Prefix += database + "/"
+		mirrors[i] = mirror
+	}
+	cfg.Mirrors = mirrors
+
+	cfg.Backup.AllDatabases = false
+	return cfg, nil
+}
@@ -0,0 +1,158 @@
+package pg2s3_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+func TestCreateDatabase(t *testing.T) {
+	cfg, database := createTestDatabase(t)
+
+	client, err := pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := client.CreateBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	// restore into a database that doesn't exist yet
+	cfg.Restore.PGURL = databaseURL(t, cfg.PGURL, database+"_copy")
+	cfg.Restore.CreateDatabase = true
+
+	client, err = pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := client.CreateDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Errorf("got %v; want %v", created, true)
+	}
+
+	// existing databases are left alone
+	created, err = client.CreateDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Errorf("got %v; want %v", created, false)
+	}
+
+	err = client.RestoreBackup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := countWidgets(t, cfg.Restore.PGURL)
+	if count != 2 {
+		t.Errorf("got %d; want %d", count, 2)
+	}
+}
+
+func TestSwapRestoreBackup(t *testing.T) {
+	cfg, database := createTestDatabase(t)
+	cfg.Restore.Swap = true
+
+	client, err := pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := client.CreateBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	// diverge the live database from the backup
+	execSQL(t, cfg.PGURL, "INSERT INTO widgets (name) VALUES ('baz')")
+
+	old, err := client.SwapRestoreBackup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old != database+"_old" {
+		t.Errorf("got %q; want %q", old, database+"_old")
+	}
+
+	// the restored database takes the place of the live one
+	count := countWidgets(t, cfg.PGURL)
+	if count != 2 {
+		t.Errorf("got %d; want %d", count, 2)
+	}
+
+	// and the previous one is kept (and still accepts connections)
+	count = countWidgets(t, databaseURL(t, cfg.PGURL, old))
+	if count != 3 {
+		t.Errorf("got %d; want %d", count, 3)
+	}
+
+	// the rollback copy is never clobbered
+	backup, err = client.CreateBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	_, err = client.SwapRestoreBackup(backup)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("got %v; want error about %q already existing", err, old)
+	}
+}
+
+func TestSwapRestoreBackupMissing(t *testing.T) {
+	cfg, database := createTestDatabase(t)
+	cfg.Restore.Swap = true
+
+	client, err := pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := client.CreateBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	// swapping fails up front when the target database doesn't exist
+	cfg.Restore.PGURL = databaseURL(t, cfg.PGURL, database+"_new")
+	client, err = pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.SwapRestoreBackup(backup)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("got %v; want error about %q not existing", err, database+"_new")
+	}
+
+	// unless it can be created, in which case the restored database is moved into place
+	cfg.Restore.CreateDatabase = true
+	client, err = pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := client.SwapRestoreBackup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old != "" {
+		t.Errorf("got %q; want no previous database", old)
+	}
+
+	count := countWidgets(t, cfg.Restore.PGURL)
+	if count != 2 {
+		t.Errorf("got %d; want %d", count, 2)
+	}
+}
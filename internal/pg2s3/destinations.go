@@ -0,0 +1,138 @@
+package pg2s3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+)
+
+// Failure to upload to a single destination
+type DestinationError struct {
+	Destination string
+	Err         error
+}
+
+func (e DestinationError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Destination, e.Err)
+}
+
+func (e DestinationError) Unwrap() error {
+	return e.Err
+}
+
+// Returned when uploading to some (but not all) destinations fails
+type PartialUploadError struct {
+	// failures in destination order (which destinations may share a description)
+	Errors []DestinationError
+}
+
+func (e *PartialUploadError) Error() string {
+	var msgs []string
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return "upload failed for some destinations: " + strings.Join(msgs, "; ")
+}
+
+// List a client for each destination that backups are uploaded to (the
+// primary destination comes first followed by any mirrors). The clients are
+// reused by later calls so that their storage connections are as well.
+func (c *Client) Destinations() []*Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.destinations != nil {
+		return c.destinations
+	}
+
+	primary := c.cfg
+	primary.Mirrors = nil
+
+	destinations := []*Client{{cfg: primary}}
+	for _, mirror := range c.cfg.Mirrors {
+		cfg := c.cfg
+		cfg.S3 = mirror
+		cfg.Mirrors = nil
+
+		destinations = append(destinations, &Client{cfg: cfg})
+	}
+
+	c.destinations = destinations
+	return destinations
+}
+
+// Describe the destination of a client (endpoint, bucket, and key prefix)
+func (c *Client) Destination() string {
+	switch c.cfg.S3.Scheme {
+	case config.SchemeFile:
+		return fmt.Sprintf("file://%s/%s", strings.TrimSuffix(c.cfg.S3.Path, "/"), c.cfg.S3.Prefix)
+	case config.SchemeSFTP:
+		return fmt.Sprintf("sftp://%s@%s%s/%s", c.cfg.S3.User, c.cfg.S3.Endpoint, strings.TrimSuffix(c.cfg.S3.Path, "/"), c.cfg.S3.Prefix)
+	default:
+		return fmt.Sprintf("%s/%s/%s", c.cfg.S3.Endpoint, c.cfg.S3.BucketName, c.cfg.S3.Prefix)
+	}
+}
+
+// Writes to an io.Pipe until the reading side fails, after which writes are
+// discarded so that a single failing destination doesn't stall the others
+type fanoutWriter struct {
+	w      *io.PipeWriter
+	failed bool
+}
+
+func (f *fanoutWriter) Write(p []byte) (int, error) {
+	if f.failed {
+		return len(p), nil
+	}
+
+	_, err := f.w.Write(p)
+	if err != nil {
+		f.failed = true
+	}
+
+	return len(p), nil
+}
+
+// Stream a single reader to each destination concurrently (without buffering
+// the whole thing). Returns an error for each destination (nil on success).
+func fanOut(r io.Reader, destinations []*Client, upload func(*Client, io.Reader) error) ([]error, error) {
+	errs := make([]error, len(destinations))
+
+	var wg sync.WaitGroup
+	var writers []io.Writer
+	var pipes []*io.PipeWriter
+	for i, destination := range destinations {
+		pr, pw := io.Pipe()
+		pipes = append(pipes, pw)
+		writers = append(writers, &fanoutWriter{w: pw})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := upload(destination, pr)
+			errs[i] = err
+
+			// unblock the writer if the upload stopped reading early
+			pr.CloseWithError(errors.Join(err, io.ErrClosedPipe))
+		}()
+	}
+
+	_, err := io.Copy(io.MultiWriter(writers...), r)
+	for _, pw := range pipes {
+		// a failed read aborts every upload
+		pw.CloseWithError(err)
+	}
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
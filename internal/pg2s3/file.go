@@ -0,0 +1,245 @@
+package pg2s3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Stores backups within a local directory (or NFS mount). Object metadata is
+// kept in a hidden sidecar file next to each object.
+type FileStorage struct {
+	root string
+}
+
+func NewFileStorage(root string) *FileStorage {
+	s := FileStorage{
+		root: root,
+	}
+	return &s
+}
+
+func (s *FileStorage) Ping() error {
+	info, err := os.Stat(s.root)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.root)
+	}
+
+	return nil
+}
+
+func (s *FileStorage) List(prefix string) ([]Object, error) {
+	dir, err := s.path(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var list []Object
+	for _, entry := range entries {
+		// skip metadata sidecars and partially written objects
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		if entry.IsDir() {
+			list = append(list, Object{Key: prefix + entry.Name() + "/"})
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, Object{
+			Key:          prefix + entry.Name(),
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	}
+
+	return list, nil
+}
+
+// Objects are written to a temporary file and renamed into place so that a
+// failed upload never leaves a truncated backup behind. The metadata sidecar is
+// also written to a temporary file and only replaced once the object has been.
+func (s *FileStorage) Put(key string, r io.Reader, size int64, metadata map[string]string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	sidecar := ""
+	if len(metadata) > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+
+		sidecar, err = writeTemp(filepath.Dir(path), "."+filepath.Base(path)+".metadata.tmp-*", data)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(sidecar)
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	// replace (or clear) the metadata now that the object is in place
+	if sidecar != "" {
+		return os.Rename(sidecar, metadataPath(path))
+	}
+
+	err = os.Remove(metadataPath(path))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Write data to a new temporary file, returning its name
+func writeTemp(dir string, pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	err = f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+func (s *FileStorage) Get(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+func (s *FileStorage) Stat(key string) (Object, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Object{}, err
+	}
+	if info.IsDir() {
+		return Object{}, fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	}
+
+	object := Object{
+		Key:          key,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}
+
+	data, err := os.ReadFile(metadataPath(path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return object, nil
+		}
+		return Object{}, err
+	}
+
+	err = json.Unmarshal(data, &object.Metadata)
+	if err != nil {
+		return Object{}, err
+	}
+
+	return object, nil
+}
+
+func (s *FileStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []string{path, metadataPath(path)} {
+		err := os.Remove(p)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *FileStorage) path(key string) (string, error) {
+	p, err := joinKey(filepath.ToSlash(s.root), key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.FromSlash(p), nil
+}
+
+// Hidden sidecar file holding an object's metadata
+func metadataPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".metadata")
+}
@@ -0,0 +1,148 @@
+package pg2s3_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+func TestFileStorage(t *testing.T) {
+	storage := pg2s3.NewFileStorage(t.TempDir())
+
+	err := storage.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := map[string]string{"Recipients": "foo,bar"}
+	err = storage.Put("prod/backup.backup", strings.NewReader("data"), -1, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.Put("prod/app/backup.backup", strings.NewReader("data"), -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// directories are listed but metadata sidecars are not
+	objects, err := storage.List("prod/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+
+	want := []string{"prod/app/", "prod/backup.backup"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %q; want %q", keys, want)
+	}
+
+	object, err := storage.Stat("prod/backup.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.Size != 4 {
+		t.Errorf("got %d; want %d", object.Size, 4)
+	}
+	if !reflect.DeepEqual(object.Metadata, metadata) {
+		t.Errorf("got %q; want %q", object.Metadata, metadata)
+	}
+
+	r, err := storage.Get("prod/backup.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("got %q; want %q", data, "data")
+	}
+
+	err = storage.Delete("prod/backup.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deleting a missing object is not an error
+	err = storage.Delete("prod/backup.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storage.Stat("prod/backup.backup")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v; want %v", err, fs.ErrNotExist)
+	}
+
+	_, err = storage.Get("prod/backup.backup")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v; want %v", err, fs.ErrNotExist)
+	}
+
+	// listing a missing prefix is empty
+	objects, err = storage.List("staging/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("got %d objects; want %d", len(objects), 0)
+	}
+}
+
+func TestFileStorageMissingRoot(t *testing.T) {
+	storage := pg2s3.NewFileStorage("/does/not/exist")
+
+	err := storage.Ping()
+	if err == nil {
+		t.Fatal("expected error for missing directory")
+	}
+}
+
+func TestFileStorageEscape(t *testing.T) {
+	dir := t.TempDir()
+	storage := pg2s3.NewFileStorage(filepath.Join(dir, "backups"))
+
+	err := os.Mkdir(filepath.Join(dir, "backups"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keys must stay within the root directory
+	for _, key := range []string{"../escape.backup", "prod/../../escape.backup"} {
+		err = storage.Put(key, strings.NewReader("data"), -1, nil)
+		if err == nil {
+			t.Errorf("key %q should be rejected", key)
+		}
+
+		_, err = storage.Get(key)
+		if err == nil {
+			t.Errorf("key %q should be rejected", key)
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "escape.backup"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v; want %v", err, fs.ErrNotExist)
+	}
+
+	// keys that stay within the root are fine
+	err = storage.Put("prod/../backup.backup", strings.NewReader("data"), -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
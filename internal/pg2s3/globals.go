@@ -0,0 +1,73 @@
+package pg2s3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Extension that identifies companion globals backups (roles, tablespaces, etc)
+const globalsExtension = ".globals.sql"
+
+// Determine the name of the globals backup that accompanies a database backup:
+// <prefix>_<timestamp>.globals.sql[.age]
+func GlobalsName(backup string) string {
+	base, _, _ := strings.Cut(backup, ".")
+
+	name := base + globalsExtension
+	if strings.HasSuffix(backup, ".age") {
+		name += ".age"
+	}
+	return name
+}
+
+func isGlobalsName(name string) bool {
+	return strings.Contains(name, globalsExtension)
+}
+
+// Dump the server's global objects (roles, tablespaces, and their privileges)
+// as plain SQL. This generally requires superuser privileges.
+func (c *Client) CreateGlobalsBackup() (io.Reader, error) {
+	args := []string{
+		"--globals-only",
+	}
+	if c.cfg.Backup.NoRolePasswords {
+		args = append(args, "--no-role-passwords")
+	}
+	args = append(args, "-d", c.cfg.PGURL)
+	cmd := exec.Command("pg_dumpall", args...)
+
+	var globals bytes.Buffer
+	cmd.Stdout = &globals
+
+	var capture bytes.Buffer
+	cmd.Stderr = &capture
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, errors.New(capture.String())
+	}
+
+	return &globals, nil
+}
+
+// Apply a globals backup to the restore target's server (via its maintenance
+// database). Roles that already exist produce errors which don't stop the
+// remaining statements from being applied, so psql's output is passed through.
+func (c *Client) RestoreGlobals(globals io.Reader) error {
+	pgURL, err := replaceDatabase(c.RestoreURL(), maintenanceDatabase)
+	if err != nil {
+		return err
+	}
+
+	// pg_dumpall output can contain psql meta-commands so it must be applied via psql
+	cmd := exec.Command("psql", "-X", "-q", "-d", pgURL, "-f", "-")
+	cmd.Stdin = globals
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
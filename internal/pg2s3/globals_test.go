@@ -0,0 +1,56 @@
+package pg2s3_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+func TestGlobalsRoundTrip(t *testing.T) {
+	// read the local development config file
+	cfg, err := config.ReadFile("../../pg2s3.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = createBucket(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	role := fmt.Sprintf("pg2s3_test_role_%d", time.Now().UnixNano())
+	drop := "DROP ROLE IF EXISTS " + pgx.Identifier{role}.Sanitize()
+
+	execSQL(t, cfg.PGURL, "CREATE ROLE "+pgx.Identifier{role}.Sanitize())
+	t.Cleanup(func() {
+		execSQL(t, cfg.PGURL, drop)
+	})
+
+	globals, err := client.CreateGlobalsBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lose the role after the backup was taken
+	execSQL(t, cfg.PGURL, drop)
+
+	err = client.RestoreGlobals(globals)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists := queryValue[bool](t, cfg.PGURL, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", role)
+	if !exists {
+		t.Errorf("role %q should have been restored", role)
+	}
+}
@@ -0,0 +1,93 @@
+package pg2s3
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Execute SQL statements (and then the contents of SQL files) against a
+// database. Execution stops at the first failure.
+func ExecuteSQL(pgURL string, statements []string, files []string) error {
+	if len(statements) == 0 && len(files) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, pgURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	for _, stmt := range statements {
+		_, err = conn.Exec(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("%q: %w", stmt, err)
+		}
+	}
+
+	// files may contain multiple statements (sent via the simple query protocol)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.Exec(ctx, string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// Details about an operation that are passed to command hooks
+type HookInfo struct {
+	Operation string
+	Backup    string
+	Size      int64
+	Duration  time.Duration
+	Status    string
+	Error     string
+}
+
+// Hook details as environment variables (only the ones that are known)
+func (h HookInfo) Environ() []string {
+	env := []string{"PG2S3_OPERATION=" + h.Operation}
+	if h.Backup != "" {
+		env = append(env, "PG2S3_BACKUP="+h.Backup)
+	}
+	if h.Size > 0 {
+		env = append(env, "PG2S3_SIZE="+strconv.FormatInt(h.Size, 10))
+	}
+	if h.Status != "" {
+		env = append(env, "PG2S3_DURATION="+strconv.FormatFloat(h.Duration.Seconds(), 'f', 3, 64))
+		env = append(env, "PG2S3_STATUS="+h.Status)
+	}
+	if h.Error != "" {
+		env = append(env, "PG2S3_ERROR="+h.Error)
+	}
+	return env
+}
+
+// Run a shell command with the given hook details added to its environment.
+// Output is passed through and a non-zero exit code is returned as an error.
+func RunCommand(command string, info HookInfo) error {
+	if command == "" {
+		return nil
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), info.Environ()...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
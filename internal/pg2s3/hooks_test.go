@@ -0,0 +1,69 @@
+package pg2s3_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+func TestHookInfoEnviron(t *testing.T) {
+	info := pg2s3.HookInfo{
+		Operation: "backup",
+		Backup:    "pg2s3_2026-10-16T00:00:00Z.backup",
+		Size:      1024,
+		Duration:  1500 * time.Millisecond,
+		Status:    "success",
+	}
+
+	got := strings.Join(info.Environ(), "\n")
+	want := strings.Join([]string{
+		"PG2S3_OPERATION=backup",
+		"PG2S3_BACKUP=pg2s3_2026-10-16T00:00:00Z.backup",
+		"PG2S3_SIZE=1024",
+		"PG2S3_DURATION=1.500",
+		"PG2S3_STATUS=success",
+	}, "\n")
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	// unknown details are omitted
+	got = strings.Join(pg2s3.HookInfo{Operation: "prune"}.Environ(), "\n")
+	if got != "PG2S3_OPERATION=prune" {
+		t.Errorf("got %q; want %q", got, "PG2S3_OPERATION=prune")
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	info := pg2s3.HookInfo{Operation: "restore"}
+
+	err := pg2s3.RunCommand(`echo "$PG2S3_OPERATION" > `+out, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "restore\n" {
+		t.Errorf("got %q; want %q", data, "restore\n")
+	}
+
+	// non-zero exit codes are errors
+	err = pg2s3.RunCommand("exit 3", info)
+	if err == nil {
+		t.Error("expected error for non-zero exit code")
+	}
+
+	// empty commands do nothing
+	err = pg2s3.RunCommand("", info)
+	if err != nil {
+		t.Error(err)
+	}
+}
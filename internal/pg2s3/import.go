@@ -0,0 +1,53 @@
+package pg2s3
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+)
+
+// Custom format dumps begin with this magic string
+const customMagic = "PGDMP"
+
+// Open an existing dump (a custom format file or a directory format directory)
+// so that it can be uploaded like any other backup. The dump is validated with
+// pg_restore first. Returns the format of the dump along with its contents.
+func (c *Client) ImportBackup(path string) (string, io.ReadCloser, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// check that pg_restore can read the dump
+	_, err = readTOC(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if info.IsDir() {
+		return config.FormatDirectory, streamDirectory(path, func() {}), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// pg_restore also accepts tar format dumps (which pg2s3 can't restore)
+	magic := make([]byte, len(customMagic))
+	_, err = io.ReadFull(f, magic)
+	if err != nil || string(magic) != customMagic {
+		f.Close()
+		return "", nil, errors.New("only custom format (pg_dump -Fc) and directory format (pg_dump -Fd) dumps are supported")
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		f.Close()
+		return "", nil, err
+	}
+
+	return config.FormatCustom, f, nil
+}
@@ -0,0 +1,128 @@
+package pg2s3
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+type TableContents struct {
+	Name string
+	// Size of the table's data within the backup (-1 if unknown)
+	Size int64
+}
+
+// Objects within a single schema of a backup
+type SchemaContents struct {
+	Schema    string
+	Tables    []TableContents
+	Indexes   []string
+	Functions []string
+}
+
+type BackupContents struct {
+	Schemas    []SchemaContents
+	Extensions []string
+}
+
+// Summarize the table of contents of a backup without restoring it. Data sizes
+// are only known for directory format backups (where each table's data is
+// stored in its own, typically compressed, file).
+func (c *Client) InspectBackup(backup io.Reader) (BackupContents, error) {
+	dir, err := os.MkdirTemp("", "pg2s3-*")
+	if err != nil {
+		return BackupContents{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := localArchive(bufio.NewReader(backup), dir)
+	if err != nil {
+		return BackupContents{}, err
+	}
+
+	entries, err := readTOC(archive)
+	if err != nil {
+		return BackupContents{}, err
+	}
+
+	sizes := dataSizes(archive, entries)
+	return SummarizeTOC(entries, sizes), nil
+}
+
+// Determine the size of each table's data file within a directory format
+// backup, returning a map of "schema.table" to size
+func dataSizes(archive string, entries []TOCEntry) map[string]int64 {
+	sizes := make(map[string]int64)
+
+	info, err := os.Stat(archive)
+	if err != nil || !info.IsDir() {
+		return sizes
+	}
+
+	for _, entry := range entries {
+		if entry.Type != "TABLE DATA" {
+			continue
+		}
+
+		// data files are named after their dump ID (with a compression suffix)
+		matches, _ := filepath.Glob(filepath.Join(archive, strconv.Itoa(entry.ID)+".dat*"))
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				continue
+			}
+
+			sizes[entry.Schema+"."+entry.Name] += info.Size()
+		}
+	}
+
+	return sizes
+}
+
+// Group the tables, indexes, functions, and extensions within a table of contents by schema
+func SummarizeTOC(entries []TOCEntry, sizes map[string]int64) BackupContents {
+	var contents BackupContents
+	schemas := make(map[string]*SchemaContents)
+
+	schema := func(name string) *SchemaContents {
+		s, ok := schemas[name]
+		if !ok {
+			s = &SchemaContents{Schema: name}
+			schemas[name] = s
+		}
+		return s
+	}
+
+	for _, entry := range entries {
+		switch entry.Type {
+		case "TABLE":
+			size, ok := sizes[entry.Schema+"."+entry.Name]
+			if !ok {
+				size = -1
+			}
+
+			s := schema(entry.Schema)
+			s.Tables = append(s.Tables, TableContents{Name: entry.Name, Size: size})
+		case "INDEX":
+			s := schema(entry.Schema)
+			s.Indexes = append(s.Indexes, entry.Name)
+		case "FUNCTION", "PROCEDURE", "AGGREGATE":
+			s := schema(entry.Schema)
+			s.Functions = append(s.Functions, entry.Name)
+		case "EXTENSION":
+			contents.Extensions = append(contents.Extensions, entry.Name)
+		}
+	}
+
+	for _, s := range schemas {
+		contents.Schemas = append(contents.Schemas, *s)
+	}
+	sort.Slice(contents.Schemas, func(i, j int) bool {
+		return contents.Schemas[i].Schema < contents.Schemas[j].Schema
+	})
+
+	return contents
+}
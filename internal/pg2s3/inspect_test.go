@@ -0,0 +1,45 @@
+package pg2s3_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+func TestSummarizeTOC(t *testing.T) {
+	entries, err := pg2s3.ParseTOC(strings.NewReader(`
+2; 3079 16386 EXTENSION - pgcrypto 
+215; 1259 16385 TABLE public users postgres
+217; 1259 16400 TABLE billing invoices postgres
+220; 1255 16410 FUNCTION public touch_updated_at() postgres
+3350; 0 16385 TABLE DATA public users postgres
+3202; 1259 16392 INDEX public users_email_idx postgres
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := map[string]int64{"public.users": 1024}
+	got := pg2s3.SummarizeTOC(entries, sizes)
+
+	want := pg2s3.BackupContents{
+		Schemas: []pg2s3.SchemaContents{
+			{
+				Schema: "billing",
+				Tables: []pg2s3.TableContents{{Name: "invoices", Size: -1}},
+			},
+			{
+				Schema:    "public",
+				Tables:    []pg2s3.TableContents{{Name: "users", Size: 1024}},
+				Indexes:   []string{"users_email_idx"},
+				Functions: []string{"touch_updated_at()"},
+			},
+		},
+		Extensions: []string{"pgcrypto"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
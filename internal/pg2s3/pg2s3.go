@@ -1,23 +1,42 @@
 package pg2s3
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"regexp"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/theandrew168/pg2s3/internal/config"
 )
 
 // Backup naming scheme:
 // <prefix>_<timestamp>.<ext>[.<ext>]*
-func GenerateBackupName(prefix string) (string, error) {
+//
+// The first extension reflects the backup's format:
+// custom    -> .backup
+// directory -> .tar
+func GenerateBackupName(prefix string, format string, timestamp time.Time) (string, error) {
 	if strings.ContainsAny(prefix, "_.") {
 		return "", errors.New("prefix must not contain '_' or '.'")
 	}
 
-	timestamp := time.Now().Format(time.RFC3339)
-	return fmt.Sprintf("%s_%s.backup", prefix, timestamp), nil
+	var ext string
+	switch format {
+	case config.FormatCustom:
+		ext = "backup"
+	case config.FormatDirectory:
+		ext = "tar"
+	default:
+		return "", fmt.Errorf("invalid backup format: %q", format)
+	}
+
+	return fmt.Sprintf("%s_%s.%s", prefix, timestamp.Format(time.RFC3339), ext), nil
 }
 
 // Parse backup timestamp by splitting on "_" or "." and parsing the 2nd element
@@ -57,3 +76,19 @@ func sortBackups(backups []string) error {
 
 	return nil
 }
+
+// Recipient fingerprint: first 8 bytes of the public key's SHA-256 hash (hex encoded)
+func RecipientFingerprint(publicKey string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(publicKey)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Describe a PostgreSQL connection string by its database, host, and port (omitting credentials)
+func DescribeDatabase(pgURL string) (string, error) {
+	cfg, err := pgx.ParseConfig(pgURL)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("database %q on %s:%d", cfg.Database, cfg.Host, cfg.Port), nil
+}
@@ -0,0 +1,252 @@
+package pg2s3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Generate the default name of the schema that recovered tables are loaded into
+func RecoverySchema(t time.Time) string {
+	return "recovery_" + t.UTC().Format("20060102")
+}
+
+// Split a table name given as "table" or "schema.table" (defaults to the public schema)
+func ParseTableName(name string) (string, string, error) {
+	schema, table, ok := strings.Cut(name, ".")
+	if !ok {
+		schema, table = "public", name
+	}
+
+	if schema == "" || table == "" || strings.Contains(table, ".") {
+		return "", "", fmt.Errorf("invalid table name: %q", name)
+	}
+
+	return schema, table, nil
+}
+
+// matches the first line of a table definition in the output of pg_restore
+var createTablePattern = regexp.MustCompile(`^CREATE (UNLOGGED )?TABLE ((?:"[^"]*"|[^\s"(])+) \(`)
+
+// matches the start of a table's data in the output of pg_restore
+var copyPattern = regexp.MustCompile(`^COPY (?:"[^"]*"|[^\s"])+ (\(.*\) )?FROM stdin;$`)
+
+// Extract a single table from a backup and load it into a different schema of
+// the restore target database (which is created if necessary). The live table
+// is left untouched so that the two versions can be compared. Returns the number
+// of rows recovered.
+func (c *Client) RecoverTable(backup io.Reader, name string, schema string) (int64, error) {
+	sourceSchema, table, err := ParseTableName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	// the backup gets read twice (once for the definition and once for the data)
+	dir, err := os.MkdirTemp("", "pg2s3-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := localArchive(bufio.NewReader(backup), dir)
+	if err != nil {
+		return 0, err
+	}
+
+	definition, err := readTableDefinition(archive, sourceSchema, table, schema)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, c.RestoreURL())
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(ctx)
+
+	// everything happens in one transaction so a failed recovery leaves nothing behind
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{schema}.Sanitize())
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, definition)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := copyTableData(ctx, tx, archive, sourceSchema, table, schema)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// Read a table's definition from a backup, rewritten to create the table in a different schema
+func readTableDefinition(archive string, schema string, table string, into string) (string, error) {
+	cmd := exec.Command(
+		"pg_restore",
+		"--schema-only",
+		"--no-owner",
+		"--no-privileges",
+		"-n", schema,
+		"-t", table,
+		"-f", "-",
+		archive,
+	)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+
+	var capture bytes.Buffer
+	cmd.Stderr = &capture
+
+	err := cmd.Run()
+	if err != nil {
+		return "", errors.New(capture.String())
+	}
+
+	// the statement runs from its CREATE TABLE line to the first line ending in a semicolon
+	var statement []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		if len(statement) == 0 {
+			match := createTablePattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+
+			line = fmt.Sprintf("CREATE %sTABLE %s (", match[1], pgx.Identifier{into, table}.Sanitize()) + line[len(match[0]):]
+		}
+
+		statement = append(statement, line)
+		if strings.HasSuffix(line, ";") {
+			return strings.Join(statement, "\n"), nil
+		}
+	}
+
+	return "", fmt.Errorf("table %s.%s not found in backup", schema, table)
+}
+
+// Copy a table's data from a backup into the same table within a different schema
+func copyTableData(ctx context.Context, tx pgx.Tx, archive string, schema string, table string, into string) (int64, error) {
+	cmd := exec.Command(
+		"pg_restore",
+		"--data-only",
+		"-n", schema,
+		"-t", table,
+		"-f", "-",
+		archive,
+	)
+
+	var capture bytes.Buffer
+	cmd.Stderr = &capture
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return 0, err
+	}
+
+	output := bufio.NewReader(stdout)
+	rows, copyErr := copyFromOutput(ctx, tx, output, pgx.Identifier{into, table}.Sanitize())
+
+	// drain any remaining output so that pg_restore can exit
+	io.Copy(io.Discard, output)
+
+	err = cmd.Wait()
+	if err != nil {
+		return 0, errors.Join(copyErr, errors.New(capture.String()))
+	}
+	if copyErr != nil {
+		return 0, copyErr
+	}
+
+	return rows, nil
+}
+
+func copyFromOutput(ctx context.Context, tx pgx.Tx, output *bufio.Reader, table string) (int64, error) {
+	for {
+		line, err := output.ReadString('\n')
+		if err != nil {
+			// a table without a COPY statement has no data
+			if errors.Is(err, io.EOF) {
+				return 0, nil
+			}
+			return 0, err
+		}
+
+		match := copyPattern.FindStringSubmatch(strings.TrimRight(line, "\n"))
+		if match == nil {
+			continue
+		}
+
+		stmt := fmt.Sprintf("COPY %s %sFROM STDIN", table, match[1])
+		tag, err := tx.Conn().PgConn().CopyFrom(ctx, &copyDataReader{r: output}, stmt)
+		if err != nil {
+			return 0, err
+		}
+
+		return tag.RowsAffected(), nil
+	}
+}
+
+// Reads the rows of a COPY ... FROM stdin block (stopping at its "\." terminator)
+type copyDataReader struct {
+	r    *bufio.Reader
+	buf  []byte
+	done bool
+}
+
+func (c *copyDataReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+
+		line, err := c.r.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+
+		if string(line) == "\\.\n" {
+			c.done = true
+			continue
+		}
+
+		c.buf = line
+	}
+
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
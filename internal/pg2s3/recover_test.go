@@ -0,0 +1,89 @@
+package pg2s3_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+func TestRecoverySchema(t *testing.T) {
+	ts := time.Date(2026, 10, 16, 23, 30, 0, 0, time.UTC)
+	got := pg2s3.RecoverySchema(ts)
+	want := "recovery_20261016"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestParseTableName(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		table  string
+	}{
+		{"users", "public", "users"},
+		{"billing.invoices", "billing", "invoices"},
+	}
+	for _, test := range tests {
+		schema, table, err := pg2s3.ParseTableName(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if schema != test.schema {
+			t.Errorf("got %q; want %q", schema, test.schema)
+		}
+		if table != test.table {
+			t.Errorf("got %q; want %q", table, test.table)
+		}
+	}
+
+	// error cases
+	for _, name := range []string{"", ".users", "public.", "a.b.c"} {
+		_, _, err := pg2s3.ParseTableName(name)
+		if err == nil {
+			t.Errorf("table name %q should be invalid", name)
+		}
+	}
+}
+
+func TestRecoverTable(t *testing.T) {
+	cfg, _ := createTestDatabase(t)
+
+	client, err := pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := client.CreateBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	// lose a row after the backup was taken
+	execSQL(t, cfg.PGURL, "DELETE FROM widgets WHERE name = 'bar'")
+
+	schema := pg2s3.RecoverySchema(time.Now())
+	rows, err := client.RecoverTable(backup, "widgets", schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 {
+		t.Errorf("got %d; want %d", rows, 2)
+	}
+
+	recovered := queryValue[int](t, cfg.PGURL, "SELECT count(*) FROM "+pgx.Identifier{schema, "widgets"}.Sanitize())
+	if recovered != 2 {
+		t.Errorf("got %d; want %d", recovered, 2)
+	}
+
+	// the live table is left untouched
+	count := countWidgets(t, cfg.PGURL)
+	if count != 1 {
+		t.Errorf("got %d; want %d", count, 1)
+	}
+}
@@ -0,0 +1,211 @@
+package pg2s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+)
+
+// Stores backups within an S3-compatible bucket
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+
+	// used to determine whether objects can be copied server-side
+	endpoint    string
+	accessKeyID string
+}
+
+func NewS3Storage(location config.S3) (*S3Storage, error) {
+	creds := credentials.NewStaticV4(
+		location.AccessKeyID,
+		location.SecretAccessKey,
+		"",
+	)
+
+	// disable HTTPS requirement for local development / testing
+	secure := true
+	if strings.Contains(location.Endpoint, "localhost") {
+		secure = false
+	}
+	if strings.Contains(location.Endpoint, "127.0.0.1") {
+		secure = false
+	}
+
+	client, err := minio.New(location.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: secure,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := S3Storage{
+		client: client,
+		bucket: location.BucketName,
+
+		endpoint:    location.Endpoint,
+		accessKeyID: location.AccessKeyID,
+	}
+	return &s, nil
+}
+
+func (s *S3Storage) Ping() error {
+	ctx := context.Background()
+	_, err := s.client.ListBuckets(ctx)
+	return err
+}
+
+func (s *S3Storage) List(prefix string) ([]Object, error) {
+	ctx := context.Background()
+	objects := s.client.ListObjects(
+		ctx,
+		s.bucket,
+		minio.ListObjectsOptions{
+			Prefix: prefix,
+		},
+	)
+
+	var list []Object
+	for object := range objects {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+
+		list = append(list, Object{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		})
+	}
+
+	return list, nil
+}
+
+func (s *S3Storage) Put(key string, r io.Reader, size int64, metadata map[string]string) error {
+	ctx := context.Background()
+	_, err := s.client.PutObject(
+		ctx,
+		s.bucket,
+		key,
+		r,
+		size,
+		minio.PutObjectOptions{
+			UserMetadata: metadata,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
+	ctx := context.Background()
+	object, err := s.client.GetObject(
+		ctx,
+		s.bucket,
+		key,
+		minio.GetObjectOptions{},
+	)
+	if err != nil {
+		return nil, s3Error(key, err)
+	}
+
+	return &s3Object{object: object, key: key}, nil
+}
+
+func (s *S3Storage) Stat(key string) (Object, error) {
+	ctx := context.Background()
+	info, err := s.client.StatObject(
+		ctx,
+		s.bucket,
+		key,
+		minio.StatObjectOptions{},
+	)
+	if err != nil {
+		return Object{}, s3Error(key, err)
+	}
+
+	object := Object{
+		Key:          key,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+		Metadata:     info.UserMetadata,
+	}
+	return object, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	ctx := context.Background()
+	return s.client.RemoveObject(
+		ctx,
+		s.bucket,
+		key,
+		minio.RemoveObjectOptions{},
+	)
+}
+
+// Copy an object from another bucket on the same server without downloading it
+// (preserving its metadata). Returns false if the source is stored elsewhere.
+func (s *S3Storage) CopyFrom(from Storage, fromKey string, key string) (bool, error) {
+	source, ok := from.(*S3Storage)
+	if !ok || source.endpoint != s.endpoint || source.accessKeyID != s.accessKeyID {
+		return false, nil
+	}
+
+	ctx := context.Background()
+	_, err := s.client.ComposeObject(
+		ctx,
+		minio.CopyDestOptions{
+			Bucket: s.bucket,
+			Object: key,
+		},
+		minio.CopySrcOptions{
+			Bucket: source.bucket,
+			Object: fromKey,
+		},
+	)
+	if err != nil {
+		return true, s3Error(fromKey, err)
+	}
+
+	return true, nil
+}
+
+// Objects are fetched lazily, so a missing key isn't reported until the first read
+type s3Object struct {
+	object *minio.Object
+	key    string
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	n, err := o.object.Read(p)
+	if err != nil && err != io.EOF {
+		return n, s3Error(o.key, err)
+	}
+
+	return n, err
+}
+
+func (o *s3Object) Close() error {
+	return o.object.Close()
+}
+
+// Report missing objects consistently with other storage backends
+func s3Error(key string, err error) error {
+	code := minio.ToErrorResponse(err).Code
+	if code == "NoSuchKey" || code == "NotFound" {
+		return fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	}
+
+	return err
+}
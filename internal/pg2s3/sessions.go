@@ -0,0 +1,235 @@
+package pg2s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Application name used by pg_restore so that its sessions can be identified
+const restoreApplicationName = "pg2s3_restore"
+
+// How long to wait for pg_restore to connect before blocking other connections
+const restoreConnectTimeout = 30 * time.Second
+
+type Session struct {
+	PID         int32
+	User        string
+	Application string
+	ClientAddr  string
+	State       string
+	Started     time.Time
+}
+
+func (s Session) String() string {
+	return fmt.Sprintf(
+		"pid=%d user=%s application=%q client=%s state=%s started=%s",
+		s.PID,
+		s.User,
+		s.Application,
+		s.ClientAddr,
+		s.State,
+		s.Started.Format(time.RFC3339),
+	)
+}
+
+func listSessions(ctx context.Context, conn *pgx.Conn, database string) ([]Session, error) {
+	rows, err := conn.Query(
+		ctx,
+		`SELECT
+			pid,
+			coalesce(usename, ''),
+			coalesce(application_name, ''),
+			coalesce(host(client_addr), 'local'),
+			coalesce(state, ''),
+			backend_start
+		FROM pg_stat_activity
+		WHERE datname = $1 AND pid <> pg_backend_pid()
+		ORDER BY backend_start`,
+		database,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := pgx.CollectRows(rows, pgx.RowToStructByPos[Session])
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// List the other sessions connected to the restore target database
+func (c *Client) ListSessions() ([]Session, error) {
+	ctx := context.Background()
+
+	conn, database, err := connectMaintenance(ctx, c.RestoreURL())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	return listSessions(ctx, conn, database)
+}
+
+// Terminate the other sessions connected to the restore target database,
+// returning how many were terminated
+func (c *Client) TerminateSessions() (int, error) {
+	ctx := context.Background()
+
+	conn, database, err := connectMaintenance(ctx, c.RestoreURL())
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(ctx)
+
+	return terminateConnections(ctx, conn, database)
+}
+
+// Restore a backup while preventing anyone else from connecting to the target
+// database. Since ALLOW_CONNECTIONS applies to everyone (pg_restore included),
+// connections are only blocked once pg_restore has established its session.
+// At that point, any other sessions are terminated. The previous value of
+// ALLOW_CONNECTIONS is restored once pg_restore finishes.
+func (c *Client) restoreBackupBlocked(backup io.Reader) error {
+	ctx := context.Background()
+
+	conn, database, err := connectMaintenance(ctx, c.RestoreURL())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var allowConnections bool
+	err = conn.QueryRow(
+		ctx,
+		"SELECT datallowconn FROM pg_database WHERE datname = $1",
+		database,
+	).Scan(&allowConnections)
+	if err != nil {
+		return err
+	}
+
+	// tag pg_restore's sessions so that they can be told apart from everyone else's
+	pgURL, err := replaceApplicationName(c.RestoreURL(), restoreApplicationName)
+	if err != nil {
+		return err
+	}
+
+	cmd, cleanup, err := c.restoreCommand(backup, pgURL)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var capture bytes.Buffer
+	cmd.Stderr = &capture
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	// wait for pg_restore to connect (or exit early)
+	var restorePID int32
+	deadline := time.Now().Add(restoreConnectTimeout)
+	for restorePID == 0 {
+		select {
+		case err := <-done:
+			if err != nil {
+				return errors.New(capture.String())
+			}
+			return nil
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			<-done
+			return errors.New("timed out waiting for pg_restore to connect")
+		}
+
+		err = conn.QueryRow(
+			ctx,
+			`SELECT coalesce(min(pid), 0)
+			FROM pg_stat_activity
+			WHERE datname = $1 AND application_name = $2`,
+			database,
+			restoreApplicationName,
+		).Scan(&restorePID)
+		if err != nil {
+			cmd.Process.Kill()
+			<-done
+			return err
+		}
+	}
+
+	// keep interrupts from leaving the database blocked (pg_restore typically
+	// receives them as well and exits, at which point the setting is restored)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	// block new connections (and restore the previous setting once finished)
+	err = setAllowConnections(ctx, conn, database, false)
+	if err != nil {
+		cmd.Process.Kill()
+		<-done
+		return err
+	}
+
+	unblock := func() error {
+		err := setAllowConnections(ctx, conn, database, allowConnections)
+		if err != nil {
+			return fmt.Errorf(
+				"%w (connections to %q are still blocked, run this to allow them again: %s)",
+				err,
+				database,
+				allowConnectionsStatement(database, allowConnections),
+			)
+		}
+		return nil
+	}
+
+	// terminate everyone except for pg_restore
+	_, err = conn.Exec(
+		ctx,
+		`SELECT pg_terminate_backend(pid)
+		FROM pg_stat_activity
+		WHERE datname = $1 AND pid <> $2 AND pid <> pg_backend_pid()`,
+		database,
+		restorePID,
+	)
+	if err != nil {
+		cmd.Process.Kill()
+		<-done
+		return errors.Join(err, unblock())
+	}
+
+	select {
+	case err = <-done:
+	case <-interrupt:
+		cmd.Process.Kill()
+		<-done
+		return errors.Join(errors.New("restore interrupted"), unblock())
+	}
+	if err != nil {
+		return errors.Join(errors.New(capture.String()), unblock())
+	}
+
+	return unblock()
+}
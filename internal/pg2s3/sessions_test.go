@@ -0,0 +1,59 @@
+package pg2s3_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+func TestRestoreBackupBlocked(t *testing.T) {
+	cfg, database := createTestDatabase(t)
+	cfg.Restore.Connections = config.ConnectionsBlock
+
+	client, err := pg2s3.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := client.CreateBackup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	// an application holding a connection open during the restore
+	ctx := context.Background()
+	app, err := pgx.Connect(ctx, cfg.PGURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close(ctx)
+
+	execSQL(t, cfg.PGURL, "INSERT INTO widgets (name) VALUES ('baz')")
+
+	err = client.RestoreBackup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the application's session was terminated
+	err = app.Ping(ctx)
+	if err == nil {
+		t.Error("application session should have been terminated")
+	}
+
+	// connections are allowed again once the restore finishes
+	allowed := queryValue[bool](t, cfg.PGURL, "SELECT datallowconn FROM pg_database WHERE datname = $1", database)
+	if !allowed {
+		t.Errorf("got %v; want %v", allowed, true)
+	}
+
+	count := countWidgets(t, cfg.PGURL)
+	if count != 2 {
+		t.Errorf("got %d; want %d", count, 2)
+	}
+}
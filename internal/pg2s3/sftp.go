@@ -0,0 +1,358 @@
+package pg2s3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+)
+
+// Stores backups within a directory on a remote host via SFTP. Like
+// FileStorage, object metadata is kept in a hidden sidecar file.
+type SFTPStorage struct {
+	addr   string
+	root   string
+	config *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *sftp.Client
+}
+
+func NewSFTPStorage(location config.S3, options config.SFTP) (*SFTPStorage, error) {
+	key, err := os.ReadFile(options.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", options.PrivateKeyFile, err)
+	}
+
+	knownHostsFile := options.KnownHostsFile
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	// only connect to hosts whose keys are already known
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s := SFTPStorage{
+		addr: location.Endpoint,
+		root: location.Path,
+		config: &ssh.ClientConfig{
+			User:            location.User,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         30 * time.Second,
+		},
+	}
+	return &s, nil
+}
+
+// Connect to the remote host (reusing the existing connection if it's still open)
+func (s *SFTPStorage) connect() (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	conn, err := ssh.Dial("tcp", s.addr, s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// forget the connection once it closes so that the next operation reconnects
+	go func() {
+		conn.Wait()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.client == client {
+			s.client = nil
+		}
+	}()
+
+	s.client = client
+	return client, nil
+}
+
+// Close the connection to the remote host (if open)
+func (s *SFTPStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		return nil
+	}
+
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
+
+func (s *SFTPStorage) Ping() error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	info, err := client.Stat(s.root)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.root, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.root)
+	}
+
+	return nil
+}
+
+func (s *SFTPStorage) List(prefix string) ([]Object, error) {
+	client, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := s.path(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := client.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var list []Object
+	for _, entry := range entries {
+		// skip metadata sidecars and partially written objects
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		if entry.IsDir() {
+			list = append(list, Object{Key: prefix + entry.Name() + "/"})
+			continue
+		}
+
+		list = append(list, Object{
+			Key:          prefix + entry.Name(),
+			Size:         entry.Size(),
+			LastModified: entry.ModTime(),
+		})
+	}
+
+	return list, nil
+}
+
+// Objects are written to a temporary file and renamed into place so that a
+// failed upload never leaves a truncated backup behind. The metadata sidecar is
+// also written to a temporary file and only replaced once the object has been.
+func (s *SFTPStorage) Put(key string, r io.Reader, size int64, metadata map[string]string) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = client.MkdirAll(path.Dir(p))
+	if err != nil {
+		return err
+	}
+
+	suffix := fmt.Sprintf(".tmp-%d", time.Now().UnixNano())
+	tmp := path.Join(path.Dir(p), "."+path.Base(p)+suffix)
+	f, err := client.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer client.Remove(tmp)
+	defer f.Close()
+
+	_, err = f.ReadFrom(r)
+	if err != nil {
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	sidecar := ""
+	if len(metadata) > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+
+		sidecar = sidecarPath(p) + suffix
+		defer client.Remove(sidecar)
+
+		err = s.writeFile(client, sidecar, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.PosixRename(tmp, p)
+	if err != nil {
+		return err
+	}
+
+	// replace (or clear) the metadata now that the object is in place
+	if sidecar != "" {
+		return client.PosixRename(sidecar, sidecarPath(p))
+	}
+
+	err = client.Remove(sidecarPath(p))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SFTPStorage) Get(key string) (io.ReadCloser, error) {
+	client, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := client.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return f, nil
+}
+
+func (s *SFTPStorage) Stat(key string) (Object, error) {
+	client, err := s.connect()
+	if err != nil {
+		return Object{}, err
+	}
+
+	p, err := s.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+
+	info, err := client.Stat(p)
+	if err != nil {
+		return Object{}, fmt.Errorf("%s: %w", key, err)
+	}
+	if info.IsDir() {
+		return Object{}, fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	}
+
+	object := Object{
+		Key:          key,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}
+
+	f, err := client.Open(sidecarPath(p))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return object, nil
+		}
+		return Object{}, err
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(&object.Metadata)
+	if err != nil {
+		return Object{}, err
+	}
+
+	return object, nil
+}
+
+func (s *SFTPStorage) Delete(key string) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []string{p, sidecarPath(p)} {
+		err := client.Remove(p)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SFTPStorage) writeFile(client *sftp.Client, p string, data []byte) error {
+	f, err := client.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *SFTPStorage) path(key string) (string, error) {
+	return joinKey(s.root, key)
+}
+
+// Hidden sidecar file holding an object's metadata (remote paths are always slash-separated)
+func sidecarPath(p string) string {
+	return path.Join(path.Dir(p), "."+path.Base(p)+".metadata")
+}
@@ -0,0 +1,281 @@
+package pg2s3_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+// Start an in-process SFTP server that only accepts the given client key,
+// returning its address and host key
+func startSFTPServer(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey) {
+	t.Helper()
+
+	_, hostPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, errors.New("unknown public key")
+			}
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveSFTP(conn, serverConfig)
+		}
+	}()
+
+	return listener.Addr().String(), hostSigner.PublicKey()
+}
+
+func serveSFTP(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	defer conn.Close()
+
+	_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for req := range requests {
+				// the subsystem name follows a 4-byte length prefix
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+			}
+		}()
+
+		go func() {
+			defer channel.Close()
+
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+		}()
+	}
+}
+
+// Write a new client key pair and a known_hosts file for the given host
+func writeSFTPCredentials(t *testing.T) (config.SFTP, ssh.PublicKey) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	options := config.SFTP{
+		PrivateKeyFile: filepath.Join(dir, "id_ed25519"),
+		KnownHostsFile: filepath.Join(dir, "known_hosts"),
+	}
+
+	err = os.WriteFile(options.PrivateKeyFile, pem.EncodeToMemory(block), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return options, clientKey
+}
+
+func TestSFTPStorage(t *testing.T) {
+	options, clientKey := writeSFTPCredentials(t)
+	addr, hostKey := startSFTPServer(t, clientKey)
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	err := os.WriteFile(options.KnownHostsFile, []byte(line+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := config.ParseS3URL("sftp://pg2s3@" + addr + t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage, err := pg2s3.NewSFTPStorage(location, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	err = storage.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := map[string]string{"Recipients": "foo,bar"}
+	err = storage.Put("prod/backup.backup", strings.NewReader("data"), -1, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.Put("prod/app/backup.backup", strings.NewReader("data"), -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// directories are listed but metadata sidecars are not
+	objects, err := storage.List("prod/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+
+	want := []string{"prod/app/", "prod/backup.backup"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %q; want %q", keys, want)
+	}
+
+	object, err := storage.Stat("prod/backup.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.Size != 4 {
+		t.Errorf("got %d; want %d", object.Size, 4)
+	}
+	if !reflect.DeepEqual(object.Metadata, metadata) {
+		t.Errorf("got %q; want %q", object.Metadata, metadata)
+	}
+
+	r, err := storage.Get("prod/backup.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("got %q; want %q", data, "data")
+	}
+
+	err = storage.Delete("prod/backup.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deleting a missing object is not an error
+	err = storage.Delete("prod/backup.backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storage.Stat("prod/backup.backup")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v; want %v", err, fs.ErrNotExist)
+	}
+
+	_, err = storage.Get("prod/backup.backup")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v; want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestSFTPStorageUnknownHost(t *testing.T) {
+	options, clientKey := writeSFTPCredentials(t)
+	addr, _ := startSFTPServer(t, clientKey)
+
+	// the host is known, but with a different key
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostKey, err := ssh.NewPublicKey(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	err = os.WriteFile(options.KnownHostsFile, []byte(line+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := config.ParseS3URL("sftp://pg2s3@" + addr + t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage, err := pg2s3.NewSFTPStorage(location, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	var keyErr *knownhosts.KeyError
+	err = storage.Ping()
+	if !errors.As(err, &keyErr) {
+		t.Errorf("got %v; want %T", err, keyErr)
+	}
+}
@@ -0,0 +1,131 @@
+package pg2s3
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+
+	"github.com/theandrew168/pg2s3/internal/shamir"
+)
+
+// Key share format:
+// PG2S3-SHARE-<threshold>-<index>-<hex>
+const sharePrefix = "PG2S3-SHARE-"
+
+type KeyShare struct {
+	Threshold int
+	Index     int
+	Data      []byte
+}
+
+func (s KeyShare) String() string {
+	return fmt.Sprintf("%s%d-%d-%s", sharePrefix, s.Threshold, s.Index, strings.ToUpper(hex.EncodeToString(s.Data)))
+}
+
+// Check if a string looks like a key share (as opposed to a private key)
+func IsKeyShare(s string) bool {
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(s)), sharePrefix)
+}
+
+func ParseKeyShare(s string) (KeyShare, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if !strings.HasPrefix(s, sharePrefix) {
+		return KeyShare{}, errors.New("invalid key share")
+	}
+
+	fields := strings.Split(strings.TrimPrefix(s, sharePrefix), "-")
+	if len(fields) != 3 {
+		return KeyShare{}, errors.New("invalid key share")
+	}
+
+	threshold, err := strconv.Atoi(fields[0])
+	if err != nil || threshold < 2 {
+		return KeyShare{}, errors.New("invalid key share threshold")
+	}
+
+	index, err := strconv.Atoi(fields[1])
+	if err != nil || index < 1 {
+		return KeyShare{}, errors.New("invalid key share index")
+	}
+
+	data, err := hex.DecodeString(fields[2])
+	if err != nil || len(data) < 2 {
+		return KeyShare{}, errors.New("invalid key share data")
+	}
+
+	// the share data ends with the x coordinate that was actually used
+	if int(data[len(data)-1]) != index {
+		return KeyShare{}, errors.New("key share index does not match its data")
+	}
+
+	share := KeyShare{
+		Threshold: threshold,
+		Index:     index,
+		Data:      data,
+	}
+	return share, nil
+}
+
+// Split a private key into n shares, any k of which can reconstruct it
+func SplitPrivateKey(privateKey string, n, k int) ([]KeyShare, error) {
+	// ensure the key is valid before splitting it up
+	_, err := age.ParseX25519Identity(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := shamir.Split([]byte(privateKey), n, k)
+	if err != nil {
+		return nil, err
+	}
+
+	var shares []KeyShare
+	for i, part := range parts {
+		share := KeyShare{
+			Threshold: k,
+			Index:     i + 1,
+			Data:      part,
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, nil
+}
+
+// Reconstruct a private key from a set of shares
+func CombineKeyShares(shares []KeyShare) (string, error) {
+	if len(shares) == 0 {
+		return "", errors.New("no key shares provided")
+	}
+
+	threshold := shares[0].Threshold
+	if len(shares) < threshold {
+		return "", fmt.Errorf("need %d key shares, only have %d", threshold, len(shares))
+	}
+
+	var parts [][]byte
+	for _, share := range shares {
+		if share.Threshold != threshold {
+			return "", errors.New("key shares have mismatched thresholds")
+		}
+		parts = append(parts, share.Data)
+	}
+
+	secret, err := shamir.Combine(parts)
+	if err != nil {
+		return "", err
+	}
+
+	// a bad set of shares produces garbage, so verify the result
+	privateKey := string(secret)
+	_, err = age.ParseX25519Identity(privateKey)
+	if err != nil {
+		return "", errors.New("key shares did not reconstruct a valid private key")
+	}
+
+	return privateKey, nil
+}
@@ -0,0 +1,66 @@
+package pg2s3_test
+
+import (
+	"testing"
+
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+func TestKeyShares(t *testing.T) {
+	shares, err := pg2s3.SplitPrivateKey(privateKey, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// round trip each share through its string representation
+	var parsed []pg2s3.KeyShare
+	for _, share := range shares[2:] {
+		s := share.String()
+		if !pg2s3.IsKeyShare(s) {
+			t.Fatalf("share %q not recognized", s)
+		}
+
+		p, err := pg2s3.ParseKeyShare(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed = append(parsed, p)
+	}
+
+	got, err := pg2s3.CombineKeyShares(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != privateKey {
+		t.Errorf("got %q; want %q", got, privateKey)
+	}
+
+	// too few shares
+	_, err = pg2s3.CombineKeyShares(parsed[:2])
+	if err == nil {
+		t.Errorf("expected error when combining fewer shares than threshold")
+	}
+}
+
+func TestParseKeyShare(t *testing.T) {
+	if pg2s3.IsKeyShare(privateKey) {
+		t.Errorf("private key %q should not be a key share", privateKey)
+	}
+
+	invalid := []string{
+		"PG2S3-SHARE-",
+		"PG2S3-SHARE-3-1",
+		"PG2S3-SHARE-1-1-ABCD",
+		"PG2S3-SHARE-3-0-ABCD",
+		"PG2S3-SHARE-3-1-XYZ",
+		"PG2S3-SHARE-3-2-ABCD01",
+	}
+	for _, s := range invalid {
+		_, err := pg2s3.ParseKeyShare(s)
+		if err == nil {
+			t.Errorf("share %q should be invalid", s)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package pg2s3
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/theandrew168/pg2s3/internal/config"
+)
+
+// An object held by a storage backend
+type Object struct {
+	// full key of the object ("directories" end in "/")
+	Key          string
+	Size         int64
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
+// Somewhere that backups can be stored. Keys are slash-separated paths and
+// missing objects are reported via errors wrapping fs.ErrNotExist.
+type Storage interface {
+	// Check that the storage is reachable
+	Ping() error
+	// List the objects and "directories" directly beneath a prefix (empty or ending in "/")
+	List(prefix string) ([]Object, error)
+	// Store an object (size may be -1 if unknown)
+	Put(key string, r io.Reader, size int64, metadata map[string]string) error
+	Get(key string) (io.ReadCloser, error)
+	Stat(key string) (Object, error)
+	// Delete an object (no error if it doesn't exist)
+	Delete(key string) error
+}
+
+// Open the storage backend for a location based on its URL scheme
+func NewStorage(location config.S3, sftp config.SFTP) (Storage, error) {
+	switch location.Scheme {
+	case config.SchemeFile:
+		return NewFileStorage(location.Path), nil
+	case config.SchemeSFTP:
+		return NewSFTPStorage(location, sftp)
+	case config.SchemeS3, "":
+		return NewS3Storage(location)
+	default:
+		return nil, fmt.Errorf("unsupported storage scheme: %q", location.Scheme)
+	}
+}
+
+// Join a key onto a (slash-separated) root directory, rejecting keys that
+// would escape it (such as "../backup")
+func joinKey(root string, key string) (string, error) {
+	root = path.Clean(root)
+	p := path.Join(root, key)
+	if p != root && !strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/") {
+		return "", fmt.Errorf("invalid key: %q", key)
+	}
+
+	return p, nil
+}
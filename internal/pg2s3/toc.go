@@ -0,0 +1,425 @@
+package pg2s3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Known TOC entry types that contain spaces (more specific types come first
+// so that "MATERIALIZED VIEW DATA" is matched before "MATERIALIZED VIEW", etc).
+// Any other type is assumed to be a single word.
+var multiWordTypes = []string{
+	"PUBLICATION TABLES IN SCHEMA",
+	"MATERIALIZED VIEW DATA",
+	"TEXT SEARCH CONFIGURATION",
+	"TEXT SEARCH DICTIONARY",
+	"FOREIGN DATA WRAPPER",
+	"PROCEDURAL LANGUAGE",
+	"DATABASE PROPERTIES",
+	"TEXT SEARCH PARSER",
+	"SUBSCRIPTION TABLE",
+	"TEXT SEARCH TEMPLATE",
+	"SEQUENCE OWNED BY",
+	"PUBLICATION TABLE",
+	"MATERIALIZED VIEW",
+	"CHECK CONSTRAINT",
+	"OPERATOR FAMILY",
+	"STATISTICS DATA",
+	"OPERATOR CLASS",
+	"BLOB METADATA",
+	"EVENT TRIGGER",
+	"FK CONSTRAINT",
+	"FOREIGN TABLE",
+	"ACCESS METHOD",
+	"INDEX ATTACH",
+	"LARGE OBJECT",
+	"ROW SECURITY",
+	"SEQUENCE SET",
+	"USER MAPPING",
+	"DEFAULT ACL",
+	"SHELL TYPE",
+	"TABLE DATA",
+}
+
+// Entry within a backup's table of contents (as listed by pg_restore -l):
+// <id>; <table oid> <oid> <type> <schema> <name> <owner>
+type TOCEntry struct {
+	ID     int
+	Type   string
+	Schema string
+	Name   string
+	Owner  string
+	Line   string
+}
+
+// Parse the output of pg_restore -l (comments and blank lines are skipped)
+func ParseTOC(r io.Reader) ([]TOCEntry, error) {
+	var entries []TOCEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// trailing whitespace is significant (it indicates an empty owner)
+		line := strings.TrimLeft(strings.TrimRight(scanner.Text(), "\r"), " \t")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		entry, err := parseTOCEntry(line)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func parseTOCEntry(line string) (TOCEntry, error) {
+	invalid := errors.New("invalid TOC entry: " + line)
+
+	id, rest, ok := strings.Cut(line, ";")
+	if !ok {
+		return TOCEntry{}, invalid
+	}
+
+	dumpID, err := strconv.Atoi(strings.TrimSpace(id))
+	if err != nil {
+		return TOCEntry{}, invalid
+	}
+
+	// skip the table oid and oid fields
+	fields := strings.Fields(rest)
+	if len(fields) < 5 {
+		return TOCEntry{}, invalid
+	}
+	fields = fields[2:]
+
+	// determine the (possibly multi-word) entry type
+	entryType := fields[0]
+	remaining := strings.Join(fields, " ")
+	for _, t := range multiWordTypes {
+		if strings.HasPrefix(remaining, t+" ") {
+			entryType = t
+			break
+		}
+	}
+	fields = fields[len(strings.Fields(entryType)):]
+
+	// remaining fields are: schema, name (may contain spaces), owner (may be empty)
+	if len(fields) < 2 {
+		return TOCEntry{}, invalid
+	}
+
+	owner := ""
+	if !strings.HasSuffix(line, " ") {
+		if len(fields) < 3 {
+			return TOCEntry{}, invalid
+		}
+
+		owner = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	entry := TOCEntry{
+		ID:     dumpID,
+		Type:   entryType,
+		Schema: fields[0],
+		Name:   strings.Join(fields[1:], " "),
+		Owner:  owner,
+		Line:   strings.TrimSpace(line),
+	}
+	return entry, nil
+}
+
+// Read the table of contents of a local backup archive (file or directory)
+func readTOC(archive string) ([]TOCEntry, error) {
+	cmd := exec.Command("pg_restore", "-l", archive)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+
+	var capture bytes.Buffer
+	cmd.Stderr = &capture
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, errors.New(capture.String())
+	}
+
+	return ParseTOC(&output)
+}
+
+// matches a (possibly quoted) schema-qualified name like public."Users"
+const qualifiedNamePattern = `(?:"[^"]+"|[^\s."]+)\.(?:"[^"]+"|[^\s."(]+)`
+
+// matches the table that an index is created on in the output of pg_restore
+var indexTablePattern = regexp.MustCompile(`(?i)^CREATE (?:UNIQUE )?INDEX .*? ON (?:ONLY )?(` + qualifiedNamePattern + `)`)
+
+// matches the table that owns a sequence (serial columns) in the output of pg_restore
+var sequenceOwnerPattern = regexp.MustCompile(`(?i)^ALTER SEQUENCE ` + qualifiedNamePattern + ` OWNED BY (` + qualifiedNamePattern + `)\.`)
+
+// matches the table of an identity column (whose sequence is created along with it)
+var identityTablePattern = regexp.MustCompile(`(?i)^ALTER TABLE (?:ONLY )?(` + qualifiedNamePattern + `) ALTER COLUMN .* ADD GENERATED .* AS IDENTITY`)
+
+// matches the header comment that pg_restore precedes each statement with:
+// -- Name: users_email_idx; Type: INDEX; Schema: public; Owner: postgres
+var objectHeaderPattern = regexp.MustCompile(`^-- Name: (.*); Type: (INDEX|SEQUENCE|SEQUENCE OWNED BY); Schema: (.*); Owner:`)
+
+// TOC entries don't say which table an index or sequence belongs to, so
+// determine that by scanning the schema definitions within the archive.
+// Returns a map of "schema.object" to "schema.table".
+func readObjectTables(archive string) (map[string]string, error) {
+	cmd := exec.Command("pg_restore", "--schema-only", "-f", "-", archive)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+
+	var capture bytes.Buffer
+	cmd.Stderr = &capture
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, errors.New(capture.String())
+	}
+
+	return ParseObjectTables(&output)
+}
+
+// Parse the tables that indexes and sequences belong to from the SQL output
+// of pg_restore. Sequences only belong to a table when owned by one of its
+// columns (serial and identity columns).
+func ParseObjectTables(r io.Reader) (map[string]string, error) {
+	tables := make(map[string]string)
+
+	var current string
+	var pattern *regexp.Regexp
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		header := objectHeaderPattern.FindStringSubmatch(line)
+		if header != nil {
+			current = header[3] + "." + header[1]
+			switch header[2] {
+			case "INDEX":
+				pattern = indexTablePattern
+			case "SEQUENCE":
+				pattern = identityTablePattern
+			case "SEQUENCE OWNED BY":
+				pattern = sequenceOwnerPattern
+			}
+			continue
+		}
+
+		// any other header ends the current object's statements
+		if strings.HasPrefix(line, "-- Name: ") {
+			current = ""
+			continue
+		}
+
+		if current == "" {
+			continue
+		}
+
+		match := pattern.FindStringSubmatch(line)
+		if match != nil {
+			tables[current] = unquoteQualifiedName(match[1])
+			current = ""
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+// convert a (possibly quoted) qualified name like public."Users" to public.Users
+func unquoteQualifiedName(name string) string {
+	var parts []string
+	for _, part := range regexp.MustCompile(`"[^"]+"|[^."]+`).FindAllString(name, -1) {
+		parts = append(parts, strings.Trim(part, `"`))
+	}
+	return strings.Join(parts, ".")
+}
+
+// TOC entry types whose names begin with the name of the table they belong to
+var tableDependentTypes = map[string]bool{
+	"CHECK CONSTRAINT": true,
+	"CONSTRAINT":       true,
+	"DEFAULT":          true,
+	"FK CONSTRAINT":    true,
+	"POLICY":           true,
+	"ROW SECURITY":     true,
+	"RULE":             true,
+	"TRIGGER":          true,
+}
+
+// TOC entry types that are named after a sequence
+var sequenceTypes = map[string]bool{
+	"SEQUENCE":          true,
+	"SEQUENCE OWNED BY": true,
+	"SEQUENCE SET":      true,
+}
+
+// Table pattern following psql's rules: "*" and "?" are wildcards, unquoted
+// letters are folded to lowercase, double quotes match their contents literally,
+// and an unqualified name matches tables within any schema.
+type tablePattern struct {
+	schema *regexp.Regexp
+	table  *regexp.Regexp
+}
+
+func compileTablePattern(pattern string) tablePattern {
+	parts := []string{""}
+	quoted := false
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '"' && quoted && i+1 < len(runes) && runes[i+1] == '"':
+			// doubled quotes within quotes are a literal quote
+			parts[len(parts)-1] += regexp.QuoteMeta(`"`)
+			i++
+		case r == '"':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			parts = append(parts, "")
+		case r == '*' && !quoted:
+			parts[len(parts)-1] += ".*"
+		case r == '?' && !quoted:
+			parts[len(parts)-1] += "."
+		default:
+			if !quoted {
+				r = unicode.ToLower(r)
+			}
+			parts[len(parts)-1] += regexp.QuoteMeta(string(r))
+		}
+	}
+
+	anchor := func(expr string) *regexp.Regexp {
+		return regexp.MustCompile("^(?:" + expr + ")$")
+	}
+
+	// a leading database name (as in "db.schema.table") is ignored
+	p := tablePattern{table: anchor(parts[len(parts)-1])}
+	if len(parts) > 1 {
+		p.schema = anchor(parts[len(parts)-2])
+	}
+	return p
+}
+
+func (p tablePattern) match(schema, table string) bool {
+	if p.schema != nil && !p.schema.MatchString(schema) {
+		return false
+	}
+	return p.table.MatchString(table)
+}
+
+// Check if a table matches a pattern (such as users, public.logs_*, or public."Users")
+func MatchTablePattern(pattern string, schema string, table string) bool {
+	return compileTablePattern(pattern).match(schema, table)
+}
+
+// Filter TOC entries by table patterns. Entries belonging to a table (its
+// definition, data, indexes, constraints, triggers, owned sequences, and
+// comments) are kept if the table matches an include pattern (or none are
+// given) and doesn't match an exclude pattern. When include patterns are
+// given, entries that don't belong to any table are dropped (like pg_restore -t).
+// The tables of indexes and sequences are looked up in objectTables (as
+// returned by ParseObjectTables).
+func FilterTables(entries []TOCEntry, include []string, exclude []string, objectTables map[string]string) []TOCEntry {
+	compile := func(patterns []string) []tablePattern {
+		var compiled []tablePattern
+		for _, pattern := range patterns {
+			compiled = append(compiled, compileTablePattern(pattern))
+		}
+		return compiled
+	}
+
+	includes := compile(include)
+	excludes := compile(exclude)
+
+	matches := func(patterns []tablePattern, schema, table string) bool {
+		for _, p := range patterns {
+			if p.match(schema, table) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var filtered []TOCEntry
+	for _, entry := range entries {
+		table := entryTable(entry, objectTables)
+		if table == "" {
+			if len(includes) == 0 {
+				filtered = append(filtered, entry)
+			}
+			continue
+		}
+
+		if len(includes) > 0 && !matches(includes, entry.Schema, table) {
+			continue
+		}
+		if matches(excludes, entry.Schema, table) {
+			continue
+		}
+
+		filtered = append(filtered, entry)
+	}
+
+	return filtered
+}
+
+// Determine the table that a TOC entry belongs to (empty if none)
+func entryTable(entry TOCEntry, objectTables map[string]string) string {
+	// look up the table of an index or sequence within the entry's schema
+	lookup := func(name string) string {
+		_, table, _ := strings.Cut(objectTables[entry.Schema+"."+name], ".")
+		return table
+	}
+
+	table := ""
+	switch {
+	case entry.Type == "TABLE" || entry.Type == "TABLE DATA":
+		table = entry.Name
+	case entry.Type == "INDEX" || sequenceTypes[entry.Type]:
+		table = lookup(entry.Name)
+	case tableDependentTypes[entry.Type]:
+		table, _, _ = strings.Cut(entry.Name, " ")
+	case entry.Type == "COMMENT" || entry.Type == "ACL":
+		// comments and ACLs are named like "TABLE users" or "COLUMN users.email"
+		kind, name, _ := strings.Cut(entry.Name, " ")
+		if kind == "TABLE" {
+			table = name
+		}
+		if kind == "COLUMN" {
+			table, _, _ = strings.Cut(name, ".")
+		}
+		if kind == "SEQUENCE" {
+			table = lookup(name)
+		}
+	}
+
+	return table
+}
@@ -0,0 +1,223 @@
+package pg2s3_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/theandrew168/pg2s3/internal/pg2s3"
+)
+
+const toc = `;
+; Archive created at 2024-01-01 00:00:00 UTC
+;     dbname: postgres
+;
+; Selected TOC Entries:
+;
+2; 3079 16386 EXTENSION - pgcrypto 
+3349; 0 0 COMMENT - EXTENSION pgcrypto 
+215; 1259 16385 TABLE public users postgres
+216; 1259 16391 MATERIALIZED VIEW public user_counts postgres
+3350; 0 16385 TABLE DATA public users postgres
+3201; 2606 16390 CONSTRAINT public users users_pkey postgres
+3202; 1259 16392 INDEX public users_email_idx postgres
+3360; 0 0 COMMENT public TABLE users postgres
+`
+
+func TestParseTOC(t *testing.T) {
+	entries, err := pg2s3.ParseTOC(strings.NewReader(toc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []pg2s3.TOCEntry{
+		{ID: 2, Type: "EXTENSION", Schema: "-", Name: "pgcrypto", Owner: ""},
+		{ID: 3349, Type: "COMMENT", Schema: "-", Name: "EXTENSION pgcrypto", Owner: ""},
+		{ID: 215, Type: "TABLE", Schema: "public", Name: "users", Owner: "postgres"},
+		{ID: 216, Type: "MATERIALIZED VIEW", Schema: "public", Name: "user_counts", Owner: "postgres"},
+		{ID: 3350, Type: "TABLE DATA", Schema: "public", Name: "users", Owner: "postgres"},
+		{ID: 3201, Type: "CONSTRAINT", Schema: "public", Name: "users users_pkey", Owner: "postgres"},
+		{ID: 3202, Type: "INDEX", Schema: "public", Name: "users_email_idx", Owner: "postgres"},
+		{ID: 3360, Type: "COMMENT", Schema: "public", Name: "TABLE users", Owner: "postgres"},
+	}
+
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries; want %d", len(entries), len(want))
+	}
+
+	for i, entry := range entries {
+		entry.Line = ""
+		if entry != want[i] {
+			t.Errorf("got %+v; want %+v", entry, want[i])
+		}
+	}
+}
+
+func TestParseTOCInvalid(t *testing.T) {
+	_, err := pg2s3.ParseTOC(strings.NewReader("not a toc entry\n"))
+	if err == nil {
+		t.Fatal("expected error for invalid TOC entry")
+	}
+}
+
+func TestMatchTablePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		schema  string
+		table   string
+		want    bool
+	}{
+		{"users", "public", "users", true},
+		{"users", "app", "users", true},
+		{"public.users", "public", "users", true},
+		{"public.users", "app", "users", false},
+		{"public.logs_*", "public", "logs_2024", true},
+		{"public.logs_*", "public", "users", false},
+		{"*.logs_?", "app", "logs_1", true},
+		{"*.logs_?", "app", "logs_10", false},
+		{"Users", "public", "users", true},
+		{"Users", "public", "Users", false},
+		{`public."Users"`, "public", "Users", true},
+		{`public."logs_*"`, "public", "logs_2024", false},
+		{`"a""b"`, "public", `a"b`, true},
+		{"db.public.users", "public", "users", true},
+	}
+
+	for _, test := range tests {
+		got := pg2s3.MatchTablePattern(test.pattern, test.schema, test.table)
+		if got != test.want {
+			t.Errorf("%q matching %s.%s: got %v; want %v", test.pattern, test.schema, test.table, got, test.want)
+		}
+	}
+}
+
+// pg_restore output for a table with a serial column and one with an identity column
+const schemaSQL = `--
+-- Name: users; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.users (
+    id integer NOT NULL,
+    email text NOT NULL
+);
+
+--
+-- Name: users_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.users_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+--
+-- Name: users_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
+
+--
+-- Name: events; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.events (
+    id bigint NOT NULL
+);
+
+--
+-- Name: events_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.events ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
+    SEQUENCE NAME public.events_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1
+);
+
+--
+-- Name: users id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);
+
+--
+-- Name: users_email_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX users_email_idx ON public.users USING btree (email);
+`
+
+const serialTOC = `;
+; Selected TOC Entries:
+;
+215; 1259 16385 TABLE public users postgres
+216; 1259 16384 SEQUENCE public users_id_seq postgres
+3361; 0 0 SEQUENCE OWNED BY public users_id_seq postgres
+217; 1259 16395 TABLE public events postgres
+218; 1259 16394 SEQUENCE public events_id_seq postgres
+3200; 2604 16388 DEFAULT public users id postgres
+3350; 0 16385 TABLE DATA public users postgres
+3351; 0 16395 TABLE DATA public events postgres
+3362; 0 0 SEQUENCE SET public users_id_seq postgres
+3363; 0 0 SEQUENCE SET public events_id_seq postgres
+3202; 1259 16392 INDEX public users_email_idx postgres
+3364; 0 0 ACL public SEQUENCE users_id_seq postgres
+`
+
+func TestParseObjectTables(t *testing.T) {
+	tables, err := pg2s3.ParseObjectTables(strings.NewReader(schemaSQL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"public.users_id_seq":    "public.users",
+		"public.events_id_seq":   "public.events",
+		"public.users_email_idx": "public.users",
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("got %v; want %v", tables, want)
+	}
+}
+
+func TestFilterTables(t *testing.T) {
+	entries, err := pg2s3.ParseTOC(strings.NewReader(serialTOC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables, err := pg2s3.ParseObjectTables(strings.NewReader(schemaSQL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []int
+	}{
+		{"include", []string{"users"}, nil, []int{215, 216, 3361, 3200, 3350, 3362, 3202, 3364}},
+		{"include identity", []string{"public.events"}, nil, []int{217, 218, 3351, 3363}},
+		{"exclude", nil, []string{"users"}, []int{217, 218, 3351, 3363}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []int
+			for _, entry := range pg2s3.FilterTables(entries, test.include, test.exclude, tables) {
+				got = append(got, entry.ID)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v; want %v", got, test.want)
+			}
+		})
+	}
+}
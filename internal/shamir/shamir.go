@@ -0,0 +1,163 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// Shamir's Secret Sharing over GF(2^8). Each byte of the secret is split
+// independently using a random polynomial whose constant term is that byte.
+// Every share is the polynomial evaluated at a unique non-zero x coordinate,
+// which is stored as the final byte of the share.
+
+// log and exp tables for GF(2^8) using the AES polynomial (x^8 + x^4 + x^3 + x + 1)
+var (
+	logTable [256]byte
+	expTable [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+
+		// multiply x by the generator (3)
+		x ^= mul2(x)
+	}
+	expTable[255] = expTable[0]
+}
+
+func mul2(b byte) byte {
+	if b&0x80 != 0 {
+		return (b << 1) ^ 0x1b
+	}
+	return b << 1
+}
+
+func add(a, b byte) byte {
+	return a ^ b
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	sum := (int(logTable[a]) + int(logTable[b])) % 255
+	return expTable[sum]
+}
+
+func div(a, b byte) byte {
+	if b == 0 {
+		panic("shamir: division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	diff := (int(logTable[a]) - int(logTable[b]) + 255) % 255
+	return expTable[diff]
+}
+
+// evaluate the polynomial (lowest degree coefficient first) at x
+func evaluate(coefficients []byte, x byte) byte {
+	// Horner's method
+	result := byte(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = add(mul(result, x), coefficients[i])
+	}
+	return result
+}
+
+// Split divides a secret into n shares, any k of which can be combined
+// to reconstruct the original secret.
+func Split(secret []byte, n, k int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("secret must not be empty")
+	}
+	if k < 2 {
+		return nil, errors.New("threshold must be at least 2")
+	}
+	if n < k {
+		return nil, errors.New("shares must be greater than or equal to threshold")
+	}
+	if n > 255 {
+		return nil, errors.New("shares must be less than 256")
+	}
+
+	// allocate each share with room for the trailing x coordinate
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, k)
+	for i, b := range secret {
+		// constant term is the secret, the rest are random
+		coefficients[0] = b
+		_, err := rand.Read(coefficients[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, share := range shares {
+			x := share[len(secret)]
+			share[i] = evaluate(coefficients, x)
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine reconstructs a secret from a set of shares. Note that combining
+// fewer shares than the original threshold yields garbage rather than an error.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least two shares are required")
+	}
+
+	size := len(shares[0])
+	if size < 2 {
+		return nil, errors.New("invalid share length")
+	}
+
+	// ensure shares are consistent and have unique x coordinates
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if len(share) != size {
+			return nil, errors.New("all shares must be the same length")
+		}
+
+		x := share[size-1]
+		if x == 0 {
+			return nil, errors.New("invalid share coordinate")
+		}
+		if seen[x] {
+			return nil, errors.New("duplicate share detected")
+		}
+		seen[x] = true
+	}
+
+	// Lagrange interpolation at x = 0 for each byte of the secret
+	secret := make([]byte, size-1)
+	for i := range secret {
+		var result byte
+		for j, shareJ := range shares {
+			xJ := shareJ[size-1]
+
+			basis := byte(1)
+			for k, shareK := range shares {
+				if j == k {
+					continue
+				}
+				xK := shareK[size-1]
+				basis = mul(basis, div(xK, add(xK, xJ)))
+			}
+
+			result = add(result, mul(shareJ[i], basis))
+		}
+		secret[i] = result
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,148 @@
+package shamir_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/theandrew168/pg2s3/internal/shamir"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("AGE-SECRET-KEY-1L54UFTSF6GUXYQMMQ8HDFYCQ59E7R80RPFLJZS3V3S0M7AFLAD4QUAFH3J")
+
+	shares, err := shamir.Split(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(shares) != 5 {
+		t.Fatalf("got %d shares; want %d", len(shares), 5)
+	}
+
+	// any combination of three shares should reconstruct the secret
+	combinations := [][]int{
+		{0, 1, 2},
+		{0, 2, 4},
+		{4, 3, 1},
+		{1, 2, 3, 4},
+	}
+	for _, indexes := range combinations {
+		var subset [][]byte
+		for _, i := range indexes {
+			subset = append(subset, shares[i])
+		}
+
+		got, err := shamir.Combine(subset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, secret) {
+			t.Errorf("shares %v: got %q; want %q", indexes, got, secret)
+		}
+	}
+
+	// two shares should not reveal the secret
+	got, err := shamir.Combine(shares[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(got, secret) {
+		t.Errorf("secret reconstructed from fewer than threshold shares")
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	secret := []byte("secret")
+
+	_, err := shamir.Split(secret, 3, 1)
+	if err == nil {
+		t.Errorf("threshold of 1 should be invalid")
+	}
+
+	_, err = shamir.Split(secret, 2, 3)
+	if err == nil {
+		t.Errorf("shares less than threshold should be invalid")
+	}
+
+	_, err = shamir.Split(secret, 256, 3)
+	if err == nil {
+		t.Errorf("more than 255 shares should be invalid")
+	}
+
+	_, err = shamir.Split(nil, 3, 2)
+	if err == nil {
+		t.Errorf("empty secret should be invalid")
+	}
+}
+
+func TestCombineInvalid(t *testing.T) {
+	shares, err := shamir.Split([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = shamir.Combine(shares[:1])
+	if err == nil {
+		t.Errorf("a single share should be invalid")
+	}
+
+	_, err = shamir.Combine([][]byte{shares[0], shares[0]})
+	if err == nil {
+		t.Errorf("duplicate shares should be invalid")
+	}
+
+	_, err = shamir.Combine([][]byte{shares[0], shares[1][1:]})
+	if err == nil {
+		t.Errorf("mismatched share lengths should be invalid")
+	}
+}
+
+// Shares of a fixed secret produced by HashiCorp Vault's shamir package
+// (same field and share layout: the y values followed by the x coordinate)
+var vaultShares = []string{
+	"19b783c8d6b60bb1b97bf341ed15a3bcc24d834348fa02dec7c9783ea2a9bec4ec85425fad9e7be26f45f93ffc1aeb25b5cb608b320d60498d9ff0c1da5254d8bcb5b2486b49fd73",
+	"05f22d51e71103816164b698f12063d3489272658912e0edf6243505708b40f08d43bb77cce4673745bcfeeef676b1fab47463b072559c5b3c168d3a60e4a7d7f3ba9c33ff97500f",
+	"1e1ea208571835c659b823aae6bb84f4de9db2330c64012aa5858b5831e0b7818f38de5400bef749693b3abaae54bba0c4e5887d4181e4c7516c91bba90dd5e91c1a9ac51db724ed",
+	"93a4da8c94a2087cdcbd3d3ef995edbcd308575a9d29869e78e0775c95074809f328ce8bc30e8de802521e91bcb9a157d835c618c681e4af0e01801421de44818a4f7b32d58738f7",
+	"b72e6051f5312996118c7420d05d69abc37b04265d406de2d09c46b4afbc3b130dc8f8429915f793a41c8cf22b12a83b006c0f7ca66391a3c5e2197bc13e99effcd9c5c9e6b5aeab",
+}
+
+func TestCombineVault(t *testing.T) {
+	secret := []byte("AGE-SECRET-KEY-1QQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQ")
+
+	var shares [][]byte
+	for _, s := range vaultShares {
+		share, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shares = append(shares, share)
+	}
+
+	// the shares were split with a threshold of three
+	combinations := [][]int{
+		{0, 1, 2},
+		{1, 2, 3},
+		{4, 0, 2},
+		{0, 1, 2, 3, 4},
+	}
+	for _, indexes := range combinations {
+		var subset [][]byte
+		for _, i := range indexes {
+			subset = append(subset, shares[i])
+		}
+
+		got, err := shamir.Combine(subset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, secret) {
+			t.Errorf("shares %v: got %q; want %q", indexes, got, secret)
+		}
+	}
+}
@@ -6,16 +6,22 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"filippo.io/age"
 	"github.com/coreos/go-systemd/v22/daemon"
 	"github.com/go-co-op/gocron/v2"
 	"golang.org/x/term"
 
 	"github.com/theandrew168/pg2s3/internal/config"
+	"github.com/theandrew168/pg2s3/internal/minisign"
 	"github.com/theandrew168/pg2s3/internal/pg2s3"
 )
 
@@ -33,25 +39,143 @@ func main() {
 
 func run() error {
 	conf := flag.String("conf", "pg2s3.conf", "pg2s3 config file")
+	job := flag.String("job", "", "name of the job to operate on (when multiple jobs are configured)")
 	flag.Parse()
 
-	cfg, err := config.ReadFile(*conf)
+	// check for action (default run)
+	args := flag.Args()
+	var action string
+	if len(args) == 0 {
+		action = "run"
+	} else {
+		action = args[0]
+		args = args[1:]
+	}
+
+	// keygen: generate a new encryption key (doesn't require a config file)
+	if action == "keygen" {
+		flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+		shares := flags.Int("shares", 0, "number of key shares to split the private key into")
+		threshold := flags.Int("threshold", 0, "number of key shares required to reconstruct the private key")
+		signing := flags.Bool("signing", false, "generate a signing key pair instead of an encryption key pair")
+		flags.Parse(args)
+
+		if *signing {
+			return keygenSigning()
+		}
+
+		return keygen(*shares, *threshold)
+	}
+
+	all, err := config.ReadFile(*conf)
 	if err != nil {
 		return err
 	}
 
-	client, err := pg2s3.NewClient(cfg)
+	// scheduled mode runs every job unless one is selected
+	if action == "run" && *job == "" && len(all.Jobs) > 1 {
+		return schedule(all.Jobs)
+	}
+
+	cfg, err := all.Job(*job)
 	if err != nil {
 		return err
 	}
 
-	// check for action (default run)
-	args := flag.Args()
-	var action string
-	if len(args) == 0 {
-		action = "run"
-	} else {
-		action = args[0]
+	// copy: copy backups between storage locations (doesn't require a database connection)
+	if action == "copy" {
+		return copyBackups(cfg, args)
+	}
+
+	// restore flags are parsed early since they can override config values
+	var opts restoreOptions
+	if action == "restore" {
+		flags := flag.NewFlagSet("restore", flag.ExitOnError)
+		flags.StringVar(&cfg.Restore.PGURL, "target", cfg.Restore.PGURL, "PostgreSQL connection string to restore into")
+		flags.BoolVar(&cfg.Restore.CreateDatabase, "create", cfg.Restore.CreateDatabase, "create the target database if it doesn't exist")
+		flags.BoolVar(&cfg.Restore.Swap, "swap", cfg.Restore.Swap, "restore into a temporary database and then swap it with the target")
+		flags.IntVar(&cfg.Restore.Jobs, "jobs", cfg.Restore.Jobs, "number of parallel pg_restore jobs")
+		flags.StringVar(&cfg.Restore.Connections, "connections", cfg.Restore.Connections, "how to handle other connections to the target (show, terminate, or block)")
+		flags.Var(&opts.identityFiles, "identity", "file containing private keys (repeatable)")
+		flags.Var(&opts.shareFiles, "share", "file containing a key share (repeatable)")
+		flags.StringVar(&opts.file, "file", "", "restore a local backup file instead of the latest backup in storage (\"-\" for stdin)")
+		flags.StringVar(&opts.signatureFile, "signature", "", "detached signature of the local backup file (defaults to <file>.minisig if present)")
+		flags.BoolVar(&opts.skipVerify, "skip-verify", false, "restore a local backup file without verifying its signature")
+		flags.StringVar(&opts.recoverTable, "recover", "", "extract a single table (\"table\" or \"schema.table\") into a separate schema")
+		flags.StringVar(&opts.recoverSchema, "into", pg2s3.RecoverySchema(time.Now()), "schema to recover the table into")
+
+		var schemas, excludeSchemas, tables, excludeTables stringsFlag
+		flags.Var(&schemas, "schema", "only restore objects in this schema (repeatable)")
+		flags.Var(&excludeSchemas, "exclude-schema", "don't restore objects in this schema (repeatable)")
+		flags.Var(&tables, "table", "only restore this table (repeatable)")
+		flags.Var(&excludeTables, "exclude-table", "don't restore this table (repeatable)")
+		flags.BoolVar(&cfg.Restore.DataOnly, "data-only", cfg.Restore.DataOnly, "only restore data, not the schema")
+		flags.BoolVar(&cfg.Restore.SchemaOnly, "schema-only", cfg.Restore.SchemaOnly, "only restore the schema, not the data")
+		flags.BoolVar(&cfg.Restore.Globals, "globals", cfg.Restore.Globals, "apply the backup's globals (roles, tablespaces) before restoring")
+		flags.BoolVar(&cfg.Restore.SingleTransaction, "single-transaction", cfg.Restore.SingleTransaction, "restore within a single transaction")
+		database := flags.String("database", "", "database to restore (required when backing up all databases)")
+		flags.Parse(args)
+
+		// repeatable flags replace (rather than extend) their config values
+		if len(schemas) > 0 {
+			cfg.Restore.Schemas = schemas
+		}
+		if len(excludeSchemas) > 0 {
+			cfg.Restore.ExcludeSchemas = excludeSchemas
+		}
+		if len(tables) > 0 {
+			cfg.Restore.Tables = tables
+		}
+		if len(excludeTables) > 0 {
+			cfg.Restore.ExcludeTables = excludeTables
+		}
+
+		// flags bypass the validation performed when reading the config
+		err = config.Validate(cfg)
+		if err != nil {
+			return err
+		}
+
+		// scope the restore to a single database (if applicable)
+		if cfg.Backup.AllDatabases {
+			if *database == "" {
+				return errors.New("restore requires -database when backup.all_databases is set")
+			}
+
+			cfg, err = pg2s3.DatabaseConfig(cfg, *database)
+			if err != nil {
+				return err
+			}
+		} else if *database != "" {
+			return errors.New("-database requires backup.all_databases")
+		}
+	}
+
+	// reading backups only involves s3_url, so an unavailable mirror shouldn't get in the way
+	switch action {
+	case "restore", "verify", "inspect", "download":
+		cfg.Mirrors = nil
+	}
+
+	// restores only require the target database to be available (and restoring
+	// a local file doesn't require storage to be available either)
+	newClient := pg2s3.NewClient
+	if action == "restore" {
+		newClient = pg2s3.NewRestoreClient
+		if opts.file != "" {
+			newClient = pg2s3.NewLocalClient
+		}
+	}
+
+	// managing existing backups doesn't require the database to be available
+	switch action {
+	case "verify", "inspect", "download", "list", "prune":
+		newClient = pg2s3.NewStorageClient
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
 	}
 
 	// backup: create a new backup
@@ -61,7 +185,32 @@ func run() error {
 
 	// restore: restore the most recent backup
 	if action == "restore" {
-		return restore(client, cfg)
+		return restore(client, cfg, opts)
+	}
+
+	// verify: verify the signature of a backup (default latest)
+	if action == "verify" {
+		return verify(client, cfg, args)
+	}
+
+	// inspect: show the contents of a backup without restoring it
+	if action == "inspect" {
+		return inspect(client, cfg, args)
+	}
+
+	// download: download a backup to a local file (or stdout)
+	if action == "download" {
+		return download(client, cfg, args)
+	}
+
+	// upload: upload an existing dump as a new backup
+	if action == "upload" {
+		return upload(client, cfg, args)
+	}
+
+	// list: list all backups along with their recipients
+	if action == "list" {
+		return list(client, cfg)
 	}
 
 	// prune: delete the oldest backups above the retention count
@@ -69,44 +218,68 @@ func run() error {
 		return prune(client, cfg)
 	}
 
-	if cfg.Backup.Schedule == "" {
-		// TODO: replace with logging
-		fmt.Println("no backup schedule specified, exiting")
-		return nil
-	}
+	return schedule([]config.Config{cfg})
+}
 
+// Run scheduled backups (followed by prunes) for each job that has a schedule
+func schedule(jobs []config.Config) error {
 	s, err := gocron.NewScheduler(
 		gocron.WithLocation(time.UTC),
 	)
 	if err != nil {
 		return err
 	}
-	_, err = s.NewJob(
-		gocron.CronJob(cfg.Backup.Schedule, false),
-		gocron.NewTask(func() {
-			err := backup(client, cfg)
-			if err != nil {
-				// TODO: replace with logging
-				fmt.Println(err)
-				return
-			}
 
-			err = prune(client, cfg)
-			if err != nil {
-				// TODO: replace with logging
-				fmt.Println(err)
-				return
-			}
-		}),
-	)
-	if err != nil {
-		return err
+	scheduled := 0
+	for _, cfg := range jobs {
+		if cfg.Backup.Schedule == "" {
+			continue
+		}
+
+		client, err := pg2s3.NewClient(cfg)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.NewJob(
+			gocron.CronJob(cfg.Backup.Schedule, false),
+			gocron.NewTask(func() {
+				err := backup(client, cfg)
+				if err != nil {
+					// TODO: replace with logging
+					fmt.Println(jobError(cfg, err))
+					return
+				}
+
+				err = prune(client, cfg)
+				if err != nil {
+					// TODO: replace with logging
+					fmt.Println(jobError(cfg, err))
+					return
+				}
+			}),
+		)
+		if err != nil {
+			return err
+		}
+
+		// TODO: replace with logging
+		if cfg.Name != "" {
+			fmt.Printf("running job %q on schedule: %s\n", cfg.Name, cfg.Backup.Schedule)
+		} else {
+			fmt.Printf("running on schedule: %s\n", cfg.Backup.Schedule)
+		}
+		scheduled++
+	}
+
+	if scheduled == 0 {
+		// TODO: replace with logging
+		fmt.Println("no backup schedule specified, exiting")
+		return nil
 	}
 
 	// let systemd know that we are good to go (no-op if not using systemd)
 	daemon.SdNotify(false, daemon.SdNotifyReady)
-	// TODO: replace with logging
-	fmt.Printf("running on schedule: %s\n", cfg.Backup.Schedule)
 
 	// create a context that cancels upon receiving an interrupt signal
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
@@ -123,8 +296,30 @@ func run() error {
 	return nil
 }
 
-func confirm(message string) bool {
-	reader := bufio.NewReader(os.Stdin)
+// prefix an error with its job's name (if applicable)
+func jobError(cfg config.Config, err error) error {
+	if cfg.Name == "" {
+		return err
+	}
+	return fmt.Errorf("job %q: %w", cfg.Name, err)
+}
+
+// flag.Value for options that can be specified multiple times
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Confirmations and prompts are read from the given input (typically stdin but
+// the terminal when stdin holds a backup)
+func confirm(input *os.File, message string) bool {
+	reader := bufio.NewReader(input)
 	fmt.Printf("%s [y/n]: ", message)
 
 	response, err := reader.ReadString('\n')
@@ -141,115 +336,1356 @@ func confirm(message string) bool {
 	}
 }
 
-func backup(client *pg2s3.Client, cfg config.Config) error {
-	// generate name for backup
-	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix)
+func prompt(input *os.File, message string) (string, error) {
+	fmt.Print(message)
+	secret, err := term.ReadPassword(int(input.Fd()))
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// create backup
-	backup, err := client.CreateBackup()
+	fmt.Println()
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// Private keys that are only read (or prompted for) once they're first needed
+type keyring struct {
+	identityFiles []string
+	shareFiles    []string
+	// where to prompt for keys from
+	input *os.File
+	keys  []string
+}
+
+func (k *keyring) privateKeys() ([]string, error) {
+	if k.keys != nil {
+		return k.keys, nil
+	}
+
+	keys, err := readPrivateKeys(k.identityFiles, k.shareFiles, k.input)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// encrypt backup (if applicable)
-	if len(cfg.Encryption.PublicKeys) > 0 {
-		backup, err = client.EncryptBackup(backup, cfg.Encryption.PublicKeys)
+	k.keys = keys
+	return keys, nil
+}
+
+// read private keys from identity files, key share files, or prompt for one
+func readPrivateKeys(identityFiles, shareFiles []string, input *os.File) ([]string, error) {
+	var privateKeys []string
+	for _, path := range identityFiles {
+		keys, err := readIdentityFile(path)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		name = name + ".age"
+		privateKeys = append(privateKeys, keys...)
 	}
 
-	// upload backup
-	err = client.UploadBackup(name, backup)
+	// only prompt if no identity files were supplied
+	if len(identityFiles) > 0 && len(shareFiles) == 0 {
+		return privateKeys, nil
+	}
+
+	privateKey, err := readPrivateKey(shareFiles, input)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeys = append(privateKeys, privateKey)
+	return privateKeys, nil
+}
+
+// read private keys from a file (one per line, blank lines and comments ignored)
+func readIdentityFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var privateKeys []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		privateKeys = append(privateKeys, line)
+	}
+
+	if len(privateKeys) == 0 {
+		return nil, fmt.Errorf("%s: no private keys found", path)
+	}
+
+	return privateKeys, nil
+}
+
+// read the private key from key share files or prompt for it (or its shares)
+func readPrivateKey(shareFiles []string, input *os.File) (string, error) {
+	var shares []pg2s3.KeyShare
+	for _, path := range shareFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		share, err := pg2s3.ParseKeyShare(string(data))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+
+		shares = append(shares, share)
+	}
+
+	if len(shareFiles) == 0 {
+		key, err := prompt(input, "enter private key (or key share): ")
+		if err != nil {
+			return "", err
+		}
+
+		if !pg2s3.IsKeyShare(key) {
+			return key, nil
+		}
+
+		share, err := pg2s3.ParseKeyShare(key)
+		if err != nil {
+			return "", err
+		}
+
+		shares = append(shares, share)
+	}
+
+	// prompt for any remaining shares needed to reach the threshold
+	threshold := shares[0].Threshold
+	for len(shares) < threshold {
+		message := fmt.Sprintf("enter key share (%d of %d): ", len(shares)+1, threshold)
+		key, err := prompt(input, message)
+		if err != nil {
+			return "", err
+		}
+
+		share, err := pg2s3.ParseKeyShare(key)
+		if err != nil {
+			return "", err
+		}
+
+		shares = append(shares, share)
+	}
+
+	return pg2s3.CombineKeyShares(shares)
+}
+
+func keygen(shares, threshold int) error {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return err
+	}
+
+	publicKey := identity.Recipient().String()
+	fmt.Printf("public key: %s (fingerprint %s)\n", publicKey, pg2s3.RecipientFingerprint(publicKey))
+
+	// print the private key as-is if not splitting it up
+	if shares == 0 && threshold == 0 {
+		fmt.Printf("private key: %s\n", identity)
+		return nil
+	}
+
+	keyShares, err := pg2s3.SplitPrivateKey(identity.String(), shares, threshold)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("created %s\n", name)
+	fmt.Printf("private key split into %d shares (%d required to restore):\n", shares, threshold)
+	for _, share := range keyShares {
+		fmt.Println(share)
+	}
+
 	return nil
 }
 
-func restore(client *pg2s3.Client, cfg config.Config) error {
-	// list all backups
-	backups, err := client.ListBackups()
+func keygenSigning() error {
+	key, err := minisign.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("public key: %s\n", key.Public())
+	fmt.Printf("private key: %s\n", key)
+	return nil
+}
+
+func backup(client *pg2s3.Client, cfg config.Config) error {
+	// back up each database on the server separately (if applicable)
+	if cfg.Backup.AllDatabases {
+		databases, err := client.ListDatabases()
+		if err != nil {
+			return err
+		}
+
+		return forEachDatabase(cfg, databases, pg2s3.NewClient, func(_ string, client *pg2s3.Client, cfg config.Config) error {
+			return backup(client, cfg)
+		})
+	}
+
+	// generate name for backup
+	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix, cfg.Backup.Format, time.Now())
 	if err != nil {
 		return err
 	}
 
-	if len(backups) == 0 {
-		return errors.New("no backups present to restore")
+	// encrypted backups get an extra suffix
+	if len(cfg.Encryption.PublicKeys) > 0 {
+		name = name + ".age"
 	}
 
-	// determine latest backup
-	latest := backups[0]
+	info := pg2s3.HookInfo{Backup: cfg.S3.Prefix + name}
+	return withCommandHooks("backup", cfg.Backup.Hooks, &info, func() error {
+		return createBackup(client, cfg, name, &info)
+	})
+}
 
-	// download backup
-	backup, err := client.DownloadBackup(latest)
+func createBackup(client *pg2s3.Client, cfg config.Config, name string, info *pg2s3.HookInfo) error {
+	// run pre-backup SQL hooks
+	hooks := cfg.Backup.Hooks
+	err := runSQLHooks("backup pre_sql", hooks, cfg.PGURL, hooks.PreSQL, hooks.PreSQLFiles)
 	if err != nil {
 		return err
 	}
 
-	// decrypt backup (if applicable)
+	// create backup
+	created, err := client.CreateBackup()
+	if err != nil {
+		return err
+	}
+	defer created.Close()
+
+	var backup io.Reader = created
+
+	// encrypt backup (if applicable)
 	if len(cfg.Encryption.PublicKeys) > 0 {
-		fmt.Print("enter private key: ")
-		input, err := term.ReadPassword(int(os.Stdin.Fd()))
+		encrypted, err := client.EncryptBackup(backup, cfg.Encryption.PublicKeys)
 		if err != nil {
 			return err
 		}
+		defer encrypted.Close()
+
+		backup = encrypted
+	}
+
+	// upload backup
+	err = uploadBackup(client, cfg, name, backup)
+	if err != nil {
+		return err
+	}
 
-		fmt.Println()
-		privateKey := string(input)
+	fmt.Printf("created %s\n", cfg.S3.Prefix+name)
 
-		backup, err = client.DecryptBackup(backup, privateKey)
+	// create and upload a companion globals backup (if applicable)
+	if cfg.Backup.Globals {
+		err = backupGlobals(client, cfg, pg2s3.GlobalsName(name))
 		if err != nil {
 			return err
 		}
 	}
 
-	// confirm restore before applying
-	message := fmt.Sprintf("restore %s", latest)
-	if !confirm(message) {
-		return nil
+	// determine the uploaded size for post hooks (from any destination that received it)
+	for _, destination := range client.Destinations() {
+		stat, err := destination.StatBackup(name)
+		if err != nil {
+			continue
+		}
+
+		info.Size = stat.Size
+		break
 	}
 
-	// restore backup
-	err = client.RestoreBackup(backup)
+	// run post-backup SQL hooks
+	return runSQLHooks("backup post_sql", hooks, cfg.PGURL, hooks.PostSQL, hooks.PostSQLFiles)
+}
+
+func backupGlobals(client *pg2s3.Client, cfg config.Config, name string) error {
+	globals, err := client.CreateGlobalsBackup()
 	if err != nil {
 		return err
 	}
-	fmt.Printf("restored %s\n", latest)
 
-	return nil
-}
+	// encrypt globals (if applicable)
+	if len(cfg.Encryption.PublicKeys) > 0 {
+		encrypted, err := client.EncryptBackup(globals, cfg.Encryption.PublicKeys)
+		if err != nil {
+			return err
+		}
+		defer encrypted.Close()
 
-func prune(client *pg2s3.Client, cfg config.Config) error {
-	// list all backups
-	backups, err := client.ListBackups()
+		globals = encrypted
+	}
+
+	err = uploadBackup(client, cfg, name, globals)
 	if err != nil {
 		return err
 	}
 
-	// exit early if retention is zero or limit hasn't been reached
-	if cfg.Backup.Retention <= 0 || len(backups) <= cfg.Backup.Retention {
+	fmt.Printf("created %s\n", cfg.S3.Prefix+name)
+	return nil
+}
+
+// Upload a backup to every destination, tolerating partial failures (if configured)
+func uploadBackup(client *pg2s3.Client, cfg config.Config, name string, backup io.Reader) error {
+	err := client.UploadBackup(name, backup, cfg.Encryption.PublicKeys)
+
+	var partial *pg2s3.PartialUploadError
+	if errors.As(err, &partial) && cfg.Backup.PartialFailure == config.PartialFailureWarn {
+		fmt.Printf("warning: %s\n", err)
 		return nil
 	}
 
-	// determine expired backups to prune
-	expired := backups[cfg.Backup.Retention:]
+	return err
+}
 
-	// prune old backups
-	for _, backup := range expired {
-		err = client.DeleteBackup(backup)
+// A reader along with whatever needs closing once it has been read
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Download a backup, verifying its signature and decrypting it (if applicable).
+// The returned backup must be closed once it has been read.
+func openBackup(client *pg2s3.Client, cfg config.Config, name string, keys *keyring) (io.ReadCloser, error) {
+	// download backup
+	downloaded, err := client.DownloadBackup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var backup io.Reader = downloaded
+
+	// verify backup signature (if applicable)
+	if cfg.Signing.PublicKey != "" {
+		backup, err = client.VerifyBackup(name, backup)
 		if err != nil {
-			return err
+			downloaded.Close()
+			return nil, err
 		}
-		fmt.Printf("deleted %s\n", backup)
 	}
 
-	return nil
+	// decrypt backup (if applicable)
+	if len(cfg.Encryption.PublicKeys) > 0 {
+		info, err := client.StatBackup(name)
+		if err != nil {
+			downloaded.Close()
+			return nil, err
+		}
+
+		backup, err = decryptBackup(client, name, backup, info.Recipients, keys, os.Stdout)
+		if err != nil {
+			downloaded.Close()
+			return nil, err
+		}
+	}
+
+	return readCloser{backup, downloaded}, nil
+}
+
+// Decrypt a backup, reporting which recipients it was encrypted to (if known)
+func decryptBackup(client *pg2s3.Client, name string, backup io.Reader, recipients []string, keys *keyring, w io.Writer) (io.Reader, error) {
+	if len(recipients) > 0 {
+		fmt.Fprintf(w, "%s is encrypted to: %s\n", name, strings.Join(recipients, ", "))
+	}
+
+	privateKeys, err := keys.privateKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	backup, err = client.DecryptBackup(backup, privateKeys...)
+	if err != nil {
+		// report which recipients the backup expects if none of the keys matched
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) && len(recipients) > 0 {
+			return nil, fmt.Errorf(
+				"none of the provided private keys match %s (expected recipients: %s)",
+				name,
+				strings.Join(recipients, ", "),
+			)
+		}
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+type restoreOptions struct {
+	identityFiles stringsFlag
+	shareFiles    stringsFlag
+	file          string
+	signatureFile string
+	skipVerify    bool
+	recoverTable  string
+	recoverSchema string
+}
+
+func restore(client *pg2s3.Client, cfg config.Config, opts restoreOptions) error {
+	// check the table to recover before downloading anything
+	if opts.recoverTable != "" {
+		_, _, err := pg2s3.ParseTableName(opts.recoverTable)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.file == "" && (opts.signatureFile != "" || opts.skipVerify) {
+		return errors.New("-signature and -skip-verify require -file")
+	}
+
+	// stdin holds the backup when restoring from it, so read confirmations and keys from the terminal instead
+	input := os.Stdin
+	if opts.file == "-" {
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			return fmt.Errorf("restoring from stdin requires a terminal: %w", err)
+		}
+		defer tty.Close()
+
+		input = tty
+	}
+
+	keys := &keyring{identityFiles: opts.identityFiles, shareFiles: opts.shareFiles, input: input}
+
+	var latest string
+	var backup, globals io.Reader
+	var info pg2s3.HookInfo
+	if opts.file != "" {
+		// restore a local file without touching storage
+		name, opened, err := openFile(client, cfg, opts, keys)
+		if err != nil {
+			return err
+		}
+		defer opened.Close()
+
+		latest = name
+		backup = opened
+
+		if cfg.Restore.Globals {
+			fmt.Println("warning: globals aren't restored from local files")
+		}
+
+		info.Backup = opts.file
+	} else {
+		// list all backups
+		backups, err := client.ListBackups()
+		if err != nil {
+			return err
+		}
+
+		if len(backups) == 0 {
+			return errors.New("no backups present to restore")
+		}
+
+		// determine latest backup
+		latest = backups[0]
+
+		downloaded, err := openBackup(client, cfg, latest, keys)
+		if err != nil {
+			return err
+		}
+		defer downloaded.Close()
+
+		backup = downloaded
+
+		// download the companion globals backup (if applicable)
+		if cfg.Restore.Globals && opts.recoverTable == "" {
+			downloaded, err := openBackup(client, cfg, pg2s3.GlobalsName(latest), keys)
+			if err != nil {
+				return fmt.Errorf("globals backup for %s: %w", latest, err)
+			}
+			defer downloaded.Close()
+
+			globals = downloaded
+		}
+
+		stat, err := client.StatBackup(latest)
+		if err != nil {
+			return err
+		}
+
+		info.Backup = cfg.S3.Prefix + latest
+		info.Size = stat.Size
+	}
+
+	// confirm restore before applying
+	target, err := pg2s3.DescribeDatabase(client.RestoreURL())
+	if err != nil {
+		return err
+	}
+
+	// recover: load a single table alongside the live one
+	if opts.recoverTable != "" {
+		message := fmt.Sprintf("recover %s from %s into schema %q of %s", opts.recoverTable, latest, opts.recoverSchema, target)
+		if !confirm(input, message) {
+			return nil
+		}
+
+		rows, err := client.RecoverTable(backup, opts.recoverTable, opts.recoverSchema)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("recovered %d row(s) of %s into schema %q\n", rows, opts.recoverTable, opts.recoverSchema)
+		return nil
+	}
+
+	// show other sessions connected to the target database (if applicable)
+	if cfg.Restore.Connections != config.ConnectionsIgnore {
+		sessions, err := client.ListSessions()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%d other session(s) connected to %s\n", len(sessions), target)
+		for _, session := range sessions {
+			fmt.Printf("  %s\n", session)
+		}
+	}
+
+	message := fmt.Sprintf("restore %s into %s", latest, target)
+	if globals != nil {
+		message += " (including globals)"
+	}
+	if cfg.Restore.Swap {
+		message += " (via swap)"
+	}
+	switch cfg.Restore.Connections {
+	case config.ConnectionsTerminate:
+		message += " (terminating other sessions)"
+	case config.ConnectionsBlock:
+		message += " (terminating and blocking other sessions)"
+	}
+	if !confirm(input, message) {
+		return nil
+	}
+
+	return withCommandHooks("restore", cfg.Restore.Hooks, &info, func() error {
+		return applyRestore(client, cfg, latest, target, backup, globals)
+	})
+}
+
+// Open a local backup file (or stdin), verifying its signature (if applicable)
+// and decrypting it if necessary. Returns a name describing the backup along
+// with its contents (which must be closed once read).
+func openFile(client *pg2s3.Client, cfg config.Config, opts restoreOptions, keys *keyring) (string, io.ReadCloser, error) {
+	name := opts.file
+
+	var opened io.ReadCloser
+	if opts.file == "-" {
+		name = "stdin"
+		opened = io.NopCloser(os.Stdin)
+	} else {
+		info, err := os.Stat(opts.file)
+		if err != nil {
+			return "", nil, err
+		}
+
+		// directory format dumps are packaged up like any other backup
+		if info.IsDir() {
+			_, opened, err = client.ImportBackup(opts.file)
+		} else {
+			opened, err = os.Open(opts.file)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	backup, err := verifyFile(client, cfg, name, opened, opts)
+	if err != nil {
+		opened.Close()
+		return "", nil, err
+	}
+
+	// decrypt backup (if applicable)
+	r := bufio.NewReader(backup)
+	if !pg2s3.IsEncrypted(r) {
+		return name, readCloser{r, opened}, nil
+	}
+
+	decrypted, err := decryptBackup(client, name, r, nil, keys, os.Stdout)
+	if err != nil {
+		opened.Close()
+		return "", nil, err
+	}
+
+	return name, readCloser{decrypted, opened}, nil
+}
+
+// Verify a local backup against its detached signature when a signing key is
+// configured. Local files aren't stored alongside their signatures, so one must
+// be given (or found next to the file) unless verification is explicitly skipped.
+func verifyFile(client *pg2s3.Client, cfg config.Config, name string, backup io.Reader, opts restoreOptions) (io.Reader, error) {
+	if cfg.Signing.PublicKey == "" {
+		if opts.signatureFile != "" {
+			return nil, errors.New("-signature requires signing.public_key")
+		}
+		return backup, nil
+	}
+
+	if opts.skipVerify {
+		fmt.Printf("warning: skipping signature verification of %s\n", name)
+		return backup, nil
+	}
+
+	signatureFile := opts.signatureFile
+	if signatureFile == "" && opts.file != "-" {
+		_, err := os.Stat(opts.file + ".minisig")
+		if err == nil {
+			signatureFile = opts.file + ".minisig"
+		}
+	}
+	if signatureFile == "" {
+		return nil, fmt.Errorf("%s can't be verified without its signature (pass -signature, or -skip-verify to restore it anyway)", name)
+	}
+
+	signature, err := os.ReadFile(signatureFile)
+	if err != nil {
+		return nil, err
+	}
+
+	verified, signedKey, err := client.VerifySignature(backup, signature)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	fmt.Printf("verified %s (signed as %s)\n", name, signedKey)
+	return verified, nil
+}
+
+func applyRestore(client *pg2s3.Client, cfg config.Config, name string, target string, backup, globals io.Reader) error {
+	// apply globals first since the database's objects may depend on its roles
+	if globals != nil {
+		err := client.RestoreGlobals(globals)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("restored %s\n", pg2s3.GlobalsName(name))
+	}
+
+	// create target database (if applicable, swaps create it by renaming)
+	if cfg.Restore.CreateDatabase && !cfg.Restore.Swap {
+		created, err := client.CreateDatabase()
+		if err != nil {
+			return err
+		}
+
+		if created {
+			fmt.Printf("created %s\n", target)
+		}
+	}
+
+	// run pre-restore SQL hooks
+	hooks := cfg.Restore.Hooks
+	err := runSQLHooks("restore pre_sql", hooks, client.RestoreURL(), hooks.PreSQL, hooks.PreSQLFiles)
+	if err != nil {
+		return err
+	}
+
+	// terminate other sessions connected to the target database (if applicable)
+	if cfg.Restore.Connections == config.ConnectionsTerminate || cfg.Restore.Connections == config.ConnectionsBlock {
+		terminated, err := client.TerminateSessions()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("terminated %d session(s)\n", terminated)
+	}
+
+	if cfg.Restore.Swap {
+		// swap: restore into a temporary database and then swap it with the target
+		old, err := client.SwapRestoreBackup(backup)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("restored %s into %s\n", name, target)
+		if old != "" {
+			fmt.Printf("previous database kept as %q (drop it once no longer needed)\n", old)
+		}
+	} else {
+		// restore backup
+		err = client.RestoreBackup(backup)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("restored %s into %s\n", name, target)
+	}
+
+	// run post-restore SQL hooks
+	return runSQLHooks("restore post_sql", hooks, client.RestoreURL(), hooks.PostSQL, hooks.PostSQLFiles)
+}
+
+// Run SQL hooks against a database, either aborting or warning upon failure
+func runSQLHooks(name string, hooks config.Hooks, pgURL string, statements []string, files []string) error {
+	err := pg2s3.ExecuteSQL(pgURL, statements, files)
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf("%s failed: %w", name, err)
+	if hooks.OnFailure == config.HookFailureWarn {
+		fmt.Printf("warning: %s\n", err)
+		return nil
+	}
+
+	return err
+}
+
+// Run an operation between its pre and post command hooks. A failing pre command
+// prevents the operation from running while the post command is always run
+// (with the status of the operation).
+func withCommandHooks(operation string, hooks config.Hooks, info *pg2s3.HookInfo, fn func() error) error {
+	info.Operation = operation
+
+	err := pg2s3.RunCommand(hooks.PreCommand, *info)
+	if err != nil {
+		return fmt.Errorf("%s pre_command failed: %w", operation, err)
+	}
+
+	start := time.Now()
+	opErr := fn()
+	info.Duration = time.Since(start)
+
+	info.Status = "success"
+	if opErr != nil {
+		info.Status = "failure"
+		info.Error = opErr.Error()
+	}
+
+	err = pg2s3.RunCommand(hooks.PostCommand, *info)
+	if err != nil {
+		err = fmt.Errorf("%s post_command failed: %w", operation, err)
+		if hooks.OnFailure == config.HookFailureWarn {
+			fmt.Printf("warning: %s\n", err)
+			err = nil
+		}
+	}
+
+	return errors.Join(opErr, err)
+}
+
+// Run an action against each of the given databases (with a client created by
+// newClient), continuing past failures
+func forEachDatabase(cfg config.Config, databases []string, newClient func(config.Config) (*pg2s3.Client, error), action func(string, *pg2s3.Client, config.Config) error) error {
+	var errs []error
+	for _, database := range databases {
+		dbCfg, err := pg2s3.DatabaseConfig(cfg, database)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", database, err))
+			continue
+		}
+
+		dbClient, err := newClient(dbCfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", database, err))
+			continue
+		}
+
+		err = action(database, dbClient, dbCfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", database, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Resolve a backup name given as "<database>/<name>" when backing up all databases
+func scopeBackup(client *pg2s3.Client, cfg config.Config, name string) (*pg2s3.Client, config.Config, string, error) {
+	if !cfg.Backup.AllDatabases {
+		return client, cfg, name, nil
+	}
+
+	database, name, ok := strings.Cut(name, "/")
+	if !ok {
+		return nil, config.Config{}, "", errors.New("backup names must be given as <database>/<name> when backup.all_databases is set")
+	}
+
+	cfg, err := pg2s3.DatabaseConfig(cfg, database)
+	if err != nil {
+		return nil, config.Config{}, "", err
+	}
+
+	// the database may have since been dropped, so only storage is required
+	client, err = pg2s3.NewStorageClient(cfg)
+	if err != nil {
+		return nil, config.Config{}, "", err
+	}
+
+	return client, cfg, name, nil
+}
+
+func verify(client *pg2s3.Client, cfg config.Config, args []string) error {
+	var name string
+	if len(args) > 0 {
+		var err error
+		client, cfg, name, err = scopeBackup(client, cfg, args[0])
+		if err != nil {
+			return err
+		}
+	} else {
+		if cfg.Backup.AllDatabases {
+			return errors.New("verify requires a backup name when backup.all_databases is set")
+		}
+
+		// list all backups
+		backups, err := client.ListBackups()
+		if err != nil {
+			return err
+		}
+
+		if len(backups) == 0 {
+			return errors.New("no backups present to verify")
+		}
+
+		// determine latest backup
+		name = backups[0]
+	}
+
+	// download backup
+	backup, err := client.DownloadBackup(name)
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+
+	_, err = client.VerifyBackup(name, backup)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("verified %s\n", cfg.S3.Prefix+name)
+	return nil
+}
+
+func inspect(client *pg2s3.Client, cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	var identityFiles, shareFiles stringsFlag
+	flags.Var(&identityFiles, "identity", "file containing private keys (repeatable)")
+	flags.Var(&shareFiles, "share", "file containing a key share (repeatable)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: pg2s3 inspect [flags] <name>")
+	}
+	client, cfg, name, err := scopeBackup(client, cfg, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	keys := &keyring{identityFiles: identityFiles, shareFiles: shareFiles, input: os.Stdin}
+	backup, err := openBackup(client, cfg, name, keys)
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+
+	contents, err := client.InspectBackup(backup)
+	if err != nil {
+		return err
+	}
+
+	if len(contents.Extensions) > 0 {
+		fmt.Printf("extensions: %s\n", strings.Join(contents.Extensions, ", "))
+	}
+
+	for _, schema := range contents.Schemas {
+		fmt.Printf("schema %s\n", schema.Schema)
+
+		if len(schema.Tables) > 0 {
+			fmt.Printf("  tables:\n")
+			for _, table := range schema.Tables {
+				if table.Size >= 0 {
+					fmt.Printf("    %s\t%d\n", table.Name, table.Size)
+				} else {
+					fmt.Printf("    %s\n", table.Name)
+				}
+			}
+		}
+
+		if len(schema.Indexes) > 0 {
+			fmt.Printf("  indexes:\n")
+			for _, index := range schema.Indexes {
+				fmt.Printf("    %s\n", index)
+			}
+		}
+
+		if len(schema.Functions) > 0 {
+			fmt.Printf("  functions:\n")
+			for _, function := range schema.Functions {
+				fmt.Printf("    %s\n", function)
+			}
+		}
+	}
+
+	return nil
+}
+
+func upload(client *pg2s3.Client, cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("upload", flag.ExitOnError)
+	timestamp := flags.String("timestamp", "", "time the dump was taken (RFC 3339 or YYYY-MM-DD, defaults to the file's modification time)")
+	database := flags.String("database", "", "database that the dump belongs to (required when backup.all_databases is set)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: pg2s3 upload [flags] <file>")
+	}
+	path := flags.Arg(0)
+
+	// scope the upload to a single database (if applicable)
+	if cfg.Backup.AllDatabases {
+		if *database == "" {
+			return errors.New("upload requires -database when backup.all_databases is set")
+		}
+
+		var err error
+		cfg, err = pg2s3.DatabaseConfig(cfg, *database)
+		if err != nil {
+			return err
+		}
+
+		client, err = pg2s3.NewClient(cfg)
+		if err != nil {
+			return err
+		}
+	} else if *database != "" {
+		return errors.New("-database requires backup.all_databases")
+	}
+
+	// name the backup after when the dump was taken
+	taken, err := parseTime(*timestamp)
+	if err != nil {
+		return err
+	}
+	if taken.IsZero() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		taken = info.ModTime()
+	}
+
+	// validate the dump and determine its format
+	format, backup, err := client.ImportBackup(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer backup.Close()
+
+	name, err := pg2s3.GenerateBackupName(cfg.Backup.Prefix, format, taken)
+	if err != nil {
+		return err
+	}
+
+	// encrypted backups get an extra suffix
+	if len(cfg.Encryption.PublicKeys) > 0 {
+		name = name + ".age"
+	}
+
+	// don't overwrite an existing backup
+	_, err = client.StatBackup(name)
+	if err == nil {
+		return fmt.Errorf("%s already exists", cfg.S3.Prefix+name)
+	}
+
+	// encrypt backup (if applicable)
+	var r io.Reader = backup
+	if len(cfg.Encryption.PublicKeys) > 0 {
+		encrypted, err := client.EncryptBackup(r, cfg.Encryption.PublicKeys)
+		if err != nil {
+			return err
+		}
+		defer encrypted.Close()
+
+		r = encrypted
+	}
+
+	err = uploadBackup(client, cfg, name, r)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created %s\n", cfg.S3.Prefix+name)
+	return nil
+}
+
+func download(client *pg2s3.Client, cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("download", flag.ExitOnError)
+	output := flags.String("o", "", "file to write the backup to (\"-\" for stdout, defaults to the backup's name)")
+	decrypt := flags.Bool("decrypt", false, "decrypt the backup (if encrypted)")
+	var identityFiles, shareFiles stringsFlag
+	flags.Var(&identityFiles, "identity", "file containing private keys (repeatable)")
+	flags.Var(&shareFiles, "share", "file containing a key share (repeatable)")
+
+	// allow flags on either side of the backup name
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return errors.New("usage: pg2s3 download [flags] <name>")
+	}
+	name := flags.Arg(0)
+	flags.Parse(flags.Args()[1:])
+	if flags.NArg() != 0 {
+		return errors.New("usage: pg2s3 download [flags] <name>")
+	}
+
+	client, cfg, name, err := scopeBackup(client, cfg, name)
+	if err != nil {
+		return err
+	}
+
+	encrypted := strings.HasSuffix(name, ".age")
+	if *output == "" {
+		*output = name
+		if *decrypt {
+			*output = strings.TrimSuffix(name, ".age")
+		}
+	}
+
+	// keep stdout clean when the backup itself is written there
+	status := io.Writer(os.Stdout)
+	if *output == "-" {
+		status = os.Stderr
+	}
+
+	downloaded, err := client.DownloadBackup(name)
+	if err != nil {
+		return err
+	}
+	defer downloaded.Close()
+
+	var backup io.Reader = downloaded
+
+	// verify the checksum of the backup (backups from older versions don't have one)
+	checksum, err := client.BackupChecksum(name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		fmt.Fprintf(status, "warning: %s has no checksum\n", name)
+	} else {
+		backup, err = pg2s3.VerifyChecksum(backup, checksum)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	// verify backup signature (if applicable)
+	if cfg.Signing.PublicKey != "" {
+		backup, err = client.VerifyBackup(name, backup)
+		if err != nil {
+			return err
+		}
+	}
+
+	// decrypt backup (if requested)
+	if *decrypt && encrypted {
+		info, err := client.StatBackup(name)
+		if err != nil {
+			return err
+		}
+
+		keys := &keyring{identityFiles: identityFiles, shareFiles: shareFiles, input: os.Stdin}
+		backup, err = decryptBackup(client, name, backup, info.Recipients, keys, status)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *output == "-" {
+		_, err = io.Copy(os.Stdout, backup)
+		return err
+	}
+
+	err = writeFileAtomic(*output, backup)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(status, "downloaded %s to %s\n", cfg.S3.Prefix+name, *output)
+	return nil
+}
+
+// Write a file via a temporary file in the same directory so that it's never
+// left partially written (existing files aren't overwritten)
+func writeFileAtomic(path string, r io.Reader) error {
+	_, err := os.Stat(path)
+	if err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func copyBackups(cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("copy", flag.ExitOnError)
+	fromURL := flags.String("from", cfg.S3URL, "storage URL to copy backups from")
+	toURL := flags.String("to", "", "storage URL to copy backups to")
+	sinceFlag := flags.String("since", "", "only copy backups taken at or after this time (RFC 3339 or YYYY-MM-DD)")
+	untilFlag := flags.String("until", "", "only copy backups taken before this time (RFC 3339 or YYYY-MM-DD)")
+	flags.Parse(args)
+
+	if *toURL == "" {
+		return errors.New("copy requires -to")
+	}
+	if *toURL == *fromURL {
+		return errors.New("copy requires -from and -to to differ")
+	}
+
+	since, err := parseTime(*sinceFlag)
+	if err != nil {
+		return err
+	}
+	until, err := parseTime(*untilFlag)
+	if err != nil {
+		return err
+	}
+
+	from, err := pg2s3.OpenLocation(*fromURL, cfg.SFTP)
+	if err != nil {
+		return err
+	}
+	to, err := pg2s3.OpenLocation(*toURL, cfg.SFTP)
+	if err != nil {
+		return err
+	}
+
+	// signatures name the full key of the backup they were made for
+	if from.Prefix != to.Prefix && cfg.Signing.PublicKey != "" {
+		fmt.Println("warning: signed backups copied to a different key prefix will fail verification")
+	}
+
+	if !cfg.Backup.AllDatabases {
+		return copyLocation(from, to, since, until, "")
+	}
+
+	// the backups of each database are kept beneath their own prefix
+	databases, err := from.ListDirectories()
+	if err != nil {
+		return err
+	}
+
+	if len(databases) == 0 {
+		return errors.New("no database backups present to copy")
+	}
+
+	var errs []error
+	for _, database := range databases {
+		err := copyLocation(from.Directory(database), to.Directory(database), since, until, database+"/")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", database, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Copy the backups taken within a time range between locations (names are
+// printed with the given prefix)
+func copyLocation(from pg2s3.Location, to pg2s3.Location, since time.Time, until time.Time, prefix string) error {
+	backups, skipped, err := from.ListBackups(since, until)
+	if err != nil {
+		return err
+	}
+
+	for _, backup := range skipped {
+		fmt.Printf("warning: skipped %s%s (can't determine when it was taken)\n", prefix, backup)
+	}
+
+	for _, backup := range backups {
+		copied, err := pg2s3.CopyBackup(from, to, backup)
+		if err != nil {
+			return fmt.Errorf("%s: %w", backup, err)
+		}
+
+		if copied {
+			fmt.Printf("copied %s%s\n", prefix, backup)
+		} else {
+			fmt.Printf("skipped %s%s (already exists)\n", prefix, backup)
+		}
+	}
+
+	return nil
+}
+
+// Parse an optional RFC 3339 timestamp or date (in UTC)
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time: %q (expected RFC 3339 or YYYY-MM-DD)", s)
+}
+
+func list(client *pg2s3.Client, cfg config.Config) error {
+	// list the backups of each database (if applicable)
+	if cfg.Backup.AllDatabases {
+		databases, err := client.ListBackupDirectories()
+		if err != nil {
+			return err
+		}
+
+		// databases may have since been dropped, so only storage is required
+		return forEachDatabase(cfg, databases, pg2s3.NewStorageClient, func(database string, client *pg2s3.Client, cfg config.Config) error {
+			return listBackups(client, database+"/")
+		})
+	}
+
+	return listBackups(client, "")
+}
+
+func listBackups(client *pg2s3.Client, prefix string) error {
+	// list the backups within each destination separately
+	destinations := client.Destinations()
+	for _, destination := range destinations {
+		if len(destinations) > 1 {
+			fmt.Printf("%s:\n", destination.Destination())
+		}
+
+		err := listDestination(destination, prefix)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func listDestination(client *pg2s3.Client, prefix string) error {
+	// list all backups
+	backups, err := client.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	for _, backup := range backups {
+		info, err := client.StatBackup(backup)
+		if err != nil {
+			return err
+		}
+
+		recipients := "-"
+		if len(info.Recipients) > 0 {
+			recipients = strings.Join(info.Recipients, ",")
+		}
+
+		fmt.Printf("%s%s\t%d\t%s\n", prefix, info.Name, info.Size, recipients)
+	}
+
+	return nil
+}
+
+func prune(client *pg2s3.Client, cfg config.Config) error {
+	// prune the backups of each database separately (if applicable)
+	if cfg.Backup.AllDatabases {
+		databases, err := client.ListBackupDirectories()
+		if err != nil {
+			return err
+		}
+
+		// databases may have since been dropped, so only storage is required
+		return forEachDatabase(cfg, databases, pg2s3.NewStorageClient, func(_ string, client *pg2s3.Client, cfg config.Config) error {
+			return prune(client, cfg)
+		})
+	}
+
+	var info pg2s3.HookInfo
+	return withCommandHooks("prune", cfg.Prune.Hooks, &info, func() error {
+		return pruneBackups(client, cfg, &info)
+	})
+}
+
+func pruneBackups(client *pg2s3.Client, cfg config.Config, info *pg2s3.HookInfo) error {
+	// apply retention to each destination separately (since they may have diverged)
+	destinations := client.Destinations()
+
+	var errs []error
+	var deleted []string
+	for _, destination := range destinations {
+		names, err := pruneDestination(destination, cfg, len(destinations) > 1)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		// post hooks receive the (space-separated) names of deleted backups
+		for _, name := range names {
+			if !slices.Contains(deleted, name) {
+				deleted = append(deleted, name)
+			}
+		}
+		info.Backup = strings.Join(deleted, " ")
+	}
+
+	return errors.Join(errs...)
+}
+
+func pruneDestination(client *pg2s3.Client, cfg config.Config, describe bool) ([]string, error) {
+	// list all backups
+	backups, err := client.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	// exit early if retention is zero or limit hasn't been reached
+	if cfg.Backup.Retention <= 0 || len(backups) <= cfg.Backup.Retention {
+		return nil, nil
+	}
+
+	// determine expired backups to prune
+	expired := backups[cfg.Backup.Retention:]
+
+	// prune old backups
+	var deleted []string
+	for _, backup := range expired {
+		err = client.DeleteBackup(backup)
+		if err != nil {
+			return deleted, err
+		}
+
+		if describe {
+			fmt.Printf("deleted %s%s\n", client.Destination(), backup)
+		} else {
+			fmt.Printf("deleted %s\n", cfg.S3.Prefix+backup)
+		}
+
+		deleted = append(deleted, backup)
+	}
+
+	return deleted, nil
 }